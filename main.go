@@ -1,19 +1,261 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/rs/zerolog/log"
 
+	"github.com/loomhq/eks-ng-ami-updater/pkg/aws"
+	"github.com/loomhq/eks-ng-ami-updater/pkg/config"
 	"github.com/loomhq/eks-ng-ami-updater/pkg/flags"
 	"github.com/loomhq/eks-ng-ami-updater/pkg/logs"
 	"github.com/loomhq/eks-ng-ami-updater/pkg/updater"
 )
 
+const exitCodePartialFailure = 2
+
 func main() {
-	debugVar, dryrunVar, skipNewerThanDays, regionsVar, nodegroupsVar, tagVar := flags.Setup()
-	ctx := logs.Setup(debugVar)
+	options := flags.Setup()
+	// a dry run of a saved plan only checks it, so the plan file is not overwritten
+	if options.Dryrun && options.PlanFile == "" && (options.Command == "" || options.Command == flags.CommandApply) {
+		options.Command = flags.CommandPlan
+	}
+	ctx := logs.Setup(options.Debug, logOutput(options.Command))
+
+	policies, err := config.Load(options.ConfigFile)
+	if err == nil {
+		options.ApplyConfigDefaults(policies.TakeFlagDefaults().SkipNewerThanDays)
+	}
+	switch options.Command {
+	case flags.CommandValidate:
+		os.Exit(validate(options.ConfigFile, err))
+	case flags.CommandConfig:
+		os.Exit(printConfig(options, err))
+	case flags.CommandHistory:
+		os.Exit(printHistory(options))
+	case "", flags.CommandApply, flags.CommandPlan, flags.CommandStatus, flags.CommandInventory, flags.CommandExplain:
+	default:
+		log.Fatal().Str("command", options.Command).Msg("Unknown command")
+	}
+	if err != nil {
+		log.Fatal().Err(err).Msg("Unable to load config file")
+	}
+	// the filter expression is compiled before any aws call, so a typo does not wait for discovery
+	_, err = updater.NewPipeline(updaterOptions(options))
+	if err != nil {
+		log.Fatal().Err(err).Msg("Unable to set up nodegroup filters")
+	}
+
+	runCtx, stop := signal.NotifyContext(ctx, syscall.SIGTERM, os.Interrupt)
+	cancel := context.CancelFunc(func() {})
+	if options.MaxRunDuration > 0 {
+		runCtx, cancel = context.WithTimeout(runCtx, options.MaxRunDuration)
+	}
+
+	throttler := aws.NewThrottler(aws.RetryOptions{
+		RateLimit:  options.ApiRateLimit,
+		Burst:      options.ApiBurst,
+		MaxRetries: options.ApiMaxRetries,
+		BaseDelay:  options.ApiRetryBaseDelay,
+		MaxDelay:   options.ApiRetryMaxDelay,
+	})
+
+	clients, err := aws.NewRealClients(throttler, aws.EndpointOptions{
+		Eks:           options.EksEndpointURL,
+		Ssm:           options.SsmEndpointURL,
+		Ec2:           options.Ec2EndpointURL,
+		Sts:           options.StsEndpointURL,
+		Organizations: options.OrgEndpointURL,
+		UseFIPS:       options.UseFIPSEndpoint,
+		UseDualStack:  options.UseDualStackEndpoint,
+		CABundle:      options.CABundle,
+		Regions:       options.Regions,
+	}, runCtx)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Unable to set up aws clients")
+	}
+
+	err = run(options, policies, clients, runCtx)
+	cancel()
+	stop()
+	throttler.LogSummary(ctx)
+
+	var updateErrors *updater.MultiError
+	if errors.As(err, &updateErrors) {
+		updateErrors.LogSummary(ctx)
+		os.Exit(exitCodePartialFailure)
+	}
+	if err != nil {
+		log.Fatal().Err(err).Str("command", options.Command).Msg("Unable to run command")
+	}
+}
+
+func run(options flags.Options, policies *config.Config, clients aws.Clients, ctx context.Context) error {
+	switch options.Command {
+	case flags.CommandPlan:
+		return runPlan(updaterOptions(options), policies, clients, ctx)
+	case flags.CommandExplain:
+		return runExplain(options, policies, clients, ctx)
+	case flags.CommandStatus:
+		updates, err := updater.Status(updaterOptions(options), clients, ctx)
+
+		return errors.Join(updater.PrintStatus(os.Stdout, updates), err)
+	case flags.CommandInventory:
+		items, err := updater.Inventory(updaterOptions(options), clients, ctx)
 
-	err := updater.UpdateAmi(dryrunVar, skipNewerThanDays, regionsVar, nodegroupsVar, tagVar, ctx)
+		return errors.Join(updater.PrintInventory(os.Stdout, items), err)
+	default:
+		return updater.UpdateAmi(updaterOptions(options), policies, clients, ctx)
+	}
+}
+
+func runPlan(options updater.Options, policies *config.Config, clients aws.Clients, ctx context.Context) error {
+	plan, err := updater.NewPlan(options, policies, clients, ctx)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Unable to update ami")
+		return err
+	}
+
+	if options.PlanFile != "" {
+		err = plan.Save(options.PlanFile)
+		if err != nil {
+			return err
+		}
+		log.Ctx(ctx).Info().Str("planFile", options.PlanFile).Int("nodegroups", len(plan.Updates)).Msg("plan is saved, review it and run 'apply' with the same '--plan' flag")
 	}
+
+	return errors.Join(updater.PrintPlan(os.Stdout, plan), plan.Err())
+}
+
+func runExplain(options flags.Options, policies *config.Config, clients aws.Clients, ctx context.Context) error {
+	if len(options.Args) == 0 {
+		return errors.New("usage: eks-ng-ami-updater explain region:cluster:nodegroup [flags]")
+	}
+
+	// explained nodegroups replace the '--nodegroups' flag, so only they are checked
+	explainOptions := updaterOptions(options)
+	explainOptions.Nodegroups = options.Args
+	plan, err := updater.NewPlan(explainOptions, policies, clients, ctx)
+	if err != nil {
+		return err
+	}
+
+	return errors.Join(updater.PrintDecisions(os.Stdout, plan.Decisions), plan.Err())
+}
+
+func updaterOptions(options flags.Options) updater.Options {
+	command := options.Command
+	if command == "" {
+		command = flags.CommandApply
+	}
+
+	return updater.Options{
+		Command:              command,
+		Dryrun:               options.Dryrun,
+		Strict:               options.Strict,
+		NoCache:              options.NoCache,
+		CacheFile:            options.CacheFile,
+		CacheTTL:             options.CacheTTL,
+		HistoryFile:          options.HistoryFile,
+		PlanFile:             options.PlanFile,
+		PlanMaxAge:           options.PlanMaxAge,
+		SkipNewerThanDays:    options.SkipNewerThanDays,
+		DiscoveryConcurrency: options.DiscoveryConcurrency,
+		UpdateStartCutoff:    options.UpdateStartCutoff,
+		UpdateWaitTimeout:    options.UpdateWaitTimeout,
+		UpdateWaitMinDelay:   options.UpdateWaitMinDelay,
+		UpdateWaitMaxDelay:   options.UpdateWaitMaxDelay,
+		Tag:                  options.Tag,
+		TagSelector:          options.TagSelector,
+		ExcludeTagSelector:   options.ExcludeTagSelector,
+		LabelSelector:        options.LabelSelector,
+		ExcludeLabelSelector: options.ExcludeLabelSelector,
+		TaintSelector:        options.TaintSelector,
+		ExcludeTaintSelector: options.ExcludeTaintSelector,
+		UpdateFirstSelector:  options.UpdateFirstSelector,
+		Filter:               options.Filter,
+		OptIn:                options.OptIn,
+		ExternalID:           options.ExternalID,
+		RoleArns:             options.RoleArns,
+		Organization:         options.Organization,
+		OrganizationTag:      options.OrganizationTag,
+		OrganizationUnits:    options.OrganizationUnits,
+		RoleArnTemplate:      options.RoleArnTemplate,
+		Regions:              options.Regions,
+		ExcludeRegions:       options.ExcludeRegions,
+		Nodegroups:           options.Nodegroups,
+	}
+}
+
+func logOutput(command string) io.Writer {
+	// reports are printed on stdout, so logs of those commands go to stderr
+	switch command {
+	case "", flags.CommandApply:
+		return os.Stdout
+	default:
+		return os.Stderr
+	}
+}
+
+func validate(configFile string, err error) int {
+	if configFile == "" {
+		fmt.Fprintln(os.Stderr, "no config file is set (eg. '--config=config.yaml')")
+
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config file (%s) is invalid:\n%s\n", configFile, err)
+
+		return 1
+	}
+	fmt.Printf("config file (%s) is valid\n", configFile)
+
+	return 0
+}
+
+func printConfig(options flags.Options, err error) int {
+	if len(options.Args) != 1 || options.Args[0] != "print" {
+		fmt.Fprintln(os.Stderr, "usage: eks-ng-ami-updater config print [flags]")
+
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "config file (%s) is invalid:\n%s\n", options.ConfigFile, err)
+
+		return 1
+	}
+
+	err = options.Print(os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		return 1
+	}
+
+	return 0
+}
+
+func printHistory(options flags.Options) int {
+	if options.HistoryFile == "" {
+		fmt.Fprintln(os.Stderr, "no history file is set (eg. '--history-file=history.jsonl')")
+
+		return 1
+	}
+
+	records, err := updater.LoadHistory(options.HistoryFile, options.HistoryLimit)
+	if err == nil {
+		err = updater.PrintHistory(os.Stdout, records)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		return 1
+	}
+
+	return 0
 }
@@ -0,0 +1,215 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	awsLib "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	organizationsTypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/loomhq/eks-ng-ami-updater/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+const roleSessionName = "eks-ng-ami-updater"
+
+func AccountIDFromRoleArn(roleArn string) (string, error) {
+	// arn:partition:iam::account-id:role/role-name
+	arnSplit := strings.Split(roleArn, ":")
+	if len(arnSplit) != 6 || arnSplit[0] != "arn" || arnSplit[2] != "iam" || arnSplit[4] == "" || !strings.HasPrefix(arnSplit[5], "role/") {
+		return "", fmt.Errorf("role arn (%s) is not valid", roleArn)
+	}
+
+	return arnSplit[4], nil
+}
+
+func (c *RealClients) AccountID(ctx context.Context) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.accountID != "" {
+		return c.accountID, nil
+	}
+
+	output, err := c.sts().GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", fmt.Errorf("error getting caller identity: %w", err)
+	}
+	c.accountID = *output.Account
+
+	return c.accountID, nil
+}
+
+func (c *RealClients) AssumeRole(roleArn, externalID string, ctx context.Context) (Clients, error) {
+	logWithContext := log.Ctx(ctx).With().Str("function", "AssumeRole").Logger()
+
+	accountID, err := PartitionForRegion(c.config.Region).RoleArnAccountID(roleArn)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := stscreds.NewAssumeRoleProvider(c.sts(), roleArn, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = roleSessionName
+		if externalID != "" {
+			o.ExternalID = awsLib.String(externalID)
+		}
+	})
+	awsConfig := c.config.Copy()
+	awsConfig.Credentials = awsLib.NewCredentialsCache(provider)
+
+	_, err = awsConfig.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error assuming role %s: %w", roleArn, err)
+	}
+	logWithContext.Debug().Str("account", accountID).Str("roleArn", roleArn).Msg("role has been assumed")
+
+	return newRealClients(awsConfig, c.endpoints, accountID, c.throttler), nil
+}
+
+func (c *RealClients) sts() *sts.Client {
+	return sts.NewFromConfig(c.config, func(o *sts.Options) {
+		setBaseEndpoint(&o.BaseEndpoint, c.endpoints.Sts)
+	})
+}
+
+func RoleArnsFromTemplate(roleArnTemplate string, partition Partition, accountIDs []string) ([]string, error) {
+	tmpl, err := template.New("role-arn").Parse(roleArnTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing role arn template: %w", err)
+	}
+
+	roleArns := make([]string, 0, len(accountIDs))
+	for _, accountID := range accountIDs {
+		var roleArn strings.Builder
+		err = tmpl.Execute(&roleArn, struct{ AccountID, Partition string }{AccountID: accountID, Partition: partition.ID})
+		if err != nil {
+			return nil, fmt.Errorf("error executing role arn template: %w", err)
+		}
+		id, err := partition.RoleArnAccountID(roleArn.String())
+		if err != nil {
+			return nil, err
+		}
+		if id != accountID {
+			return nil, fmt.Errorf("role arn (%s) built from the template is not in account %s", roleArn.String(), accountID)
+		}
+		roleArns = append(roleArns, roleArn.String())
+	}
+
+	return roleArns, nil
+}
+
+func GetOrganizationAccounts(organizationalUnits []string, tag string, awsOrganizations Organizations, ctx context.Context) ([]string, error) {
+	var accounts []organizationsTypes.Account
+	var accountIDs []string
+
+	logWithContext := log.Ctx(ctx).With().Str("function", "GetOrganizationAccounts").Logger()
+
+	// the tag is split at the first colon, so its value may contain colons but its key may not
+	tagKey, tagValue, hasValue := strings.Cut(tag, ":")
+	if tag != "" && !hasValue {
+		return nil, fmt.Errorf("organization tag (%s) has to be in the key:value format", tag)
+	}
+
+	if len(organizationalUnits) == 0 {
+		paginator := organizations.NewListAccountsPaginator(awsOrganizations, &organizations.ListAccountsInput{})
+		for paginator.HasMorePages() {
+			output, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, err
+			}
+			accounts = append(accounts, output.Accounts...)
+		}
+	}
+	for _, organizationalUnit := range organizationalUnits {
+		unitAccounts, err := getOrganizationalUnitAccounts(organizationalUnit, awsOrganizations, ctx)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, unitAccounts...)
+	}
+
+	for _, account := range accounts {
+		accountID := *account.Id
+		if account.State != organizationsTypes.AccountStateActive || utils.Contains(accountIDs, accountID) {
+			logWithContext.Debug().Str("account", accountID).Str("state", string(account.State)).Msg("skip account which is not active or is already selected")
+
+			continue
+		}
+		if tag != "" {
+			hasTag, err := hasAccountTag(accountID, tagKey, tagValue, awsOrganizations, ctx)
+			if err != nil {
+				return nil, err
+			}
+			if !hasTag {
+				logWithContext.Debug().Str("account", accountID).Str("tagVar", tag).Msg("skip account without the tag")
+
+				continue
+			}
+		}
+		accountIDs = append(accountIDs, accountID)
+	}
+	sort.Strings(accountIDs)
+
+	logWithContext.Debug().Strs("accounts", accountIDs).Strs("organizationalUnits", organizationalUnits).Msg("organization accounts have been selected")
+
+	return accountIDs, nil
+}
+
+func getOrganizationalUnitAccounts(organizationalUnit string, awsOrganizations Organizations, ctx context.Context) ([]organizationsTypes.Account, error) {
+	var accounts []organizationsTypes.Account
+
+	accountsPaginator := organizations.NewListAccountsForParentPaginator(awsOrganizations, &organizations.ListAccountsForParentInput{
+		ParentId: &organizationalUnit,
+	})
+	for accountsPaginator.HasMorePages() {
+		output, err := accountsPaginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, output.Accounts...)
+	}
+
+	// accounts of the nested organizational units belong to the parent one as well
+	unitsPaginator := organizations.NewListOrganizationalUnitsForParentPaginator(awsOrganizations, &organizations.ListOrganizationalUnitsForParentInput{
+		ParentId: &organizationalUnit,
+	})
+	for unitsPaginator.HasMorePages() {
+		output, err := unitsPaginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, unit := range output.OrganizationalUnits {
+			unitAccounts, err := getOrganizationalUnitAccounts(*unit.Id, awsOrganizations, ctx)
+			if err != nil {
+				return nil, err
+			}
+			accounts = append(accounts, unitAccounts...)
+		}
+	}
+
+	return accounts, nil
+}
+
+func hasAccountTag(accountID, key, value string, awsOrganizations Organizations, ctx context.Context) (bool, error) {
+	paginator := organizations.NewListTagsForResourcePaginator(awsOrganizations, &organizations.ListTagsForResourceInput{
+		ResourceId: &accountID,
+	})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return false, err
+		}
+		for _, accountTag := range output.Tags {
+			if *accountTag.Key == key && *accountTag.Value == value {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,119 @@
+package aws
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccountIDFromRoleArn(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		roleArn       string
+		expectedValue string
+		expectedError bool
+	}{
+		{name: "role arn",
+			roleArn:       "arn:aws:iam::111111111111:role/eks-ng-ami-updater",
+			expectedValue: "111111111111",
+		},
+		{name: "role arn with path",
+			roleArn:       "arn:aws:iam::111111111111:role/automation/eks-ng-ami-updater",
+			expectedValue: "111111111111",
+		},
+		{name: "role arn in other partition",
+			roleArn:       "arn:aws-us-gov:iam::111111111111:role/eks-ng-ami-updater",
+			expectedValue: "111111111111",
+		},
+		{name: "user arn",
+			roleArn:       "arn:aws:iam::111111111111:user/eks-ng-ami-updater",
+			expectedError: true,
+		},
+		{name: "role arn without account",
+			roleArn:       "arn:aws:iam:::role/eks-ng-ami-updater",
+			expectedError: true,
+		},
+		{name: "role name",
+			roleArn:       "eks-ng-ami-updater",
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+
+		output, err := AccountIDFromRoleArn(test.roleArn)
+
+		assert.Equal(t, test.expectedValue, output)
+		assert.Equal(t, test.expectedError, err != nil)
+	}
+}
+
+func TestRoleArnsFromTemplate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		roleArnTemplate string
+		region          string
+		accountIDs      []string
+		expectedValue   []string
+		expectedError   bool
+	}{
+		{name: "role arn for each account",
+			roleArnTemplate: "arn:aws:iam::{{.AccountID}}:role/eks-ng-ami-updater",
+			accountIDs:      []string{"111111111111", "222222222222"},
+			expectedValue:   []string{"arn:aws:iam::111111111111:role/eks-ng-ami-updater", "arn:aws:iam::222222222222:role/eks-ng-ami-updater"},
+		},
+		{name: "role arn in govcloud partition",
+			roleArnTemplate: "arn:{{.Partition}}:iam::{{.AccountID}}:role/eks-ng-ami-updater",
+			region:          "us-gov-west-1",
+			accountIDs:      []string{"111111111111"},
+			expectedValue:   []string{"arn:aws-us-gov:iam::111111111111:role/eks-ng-ami-updater"},
+		},
+		{name: "role arn in china partition",
+			roleArnTemplate: "arn:{{.Partition}}:iam::{{.AccountID}}:role/eks-ng-ami-updater",
+			region:          "cn-northwest-1",
+			accountIDs:      []string{"111111111111"},
+			expectedValue:   []string{"arn:aws-cn:iam::111111111111:role/eks-ng-ami-updater"},
+		},
+		{name: "template with hardcoded commercial partition in china",
+			roleArnTemplate: "arn:aws:iam::{{.AccountID}}:role/eks-ng-ami-updater",
+			region:          "cn-north-1",
+			accountIDs:      []string{"111111111111"},
+			expectedError:   true,
+		},
+		{name: "template with unknown field",
+			roleArnTemplate: "arn:aws:iam::{{.Account}}:role/eks-ng-ami-updater",
+			accountIDs:      []string{"111111111111"},
+			expectedError:   true,
+		},
+		{name: "template with invalid syntax",
+			roleArnTemplate: "arn:aws:iam::{{.AccountID:role/eks-ng-ami-updater",
+			accountIDs:      []string{"111111111111"},
+			expectedError:   true,
+		},
+		{name: "template without account id",
+			roleArnTemplate: "arn:aws:iam::111111111111:role/eks-ng-ami-updater",
+			accountIDs:      []string{"222222222222"},
+			expectedError:   true,
+		},
+		{name: "template which is not a role arn",
+			roleArnTemplate: "{{.AccountID}}",
+			accountIDs:      []string{"111111111111"},
+			expectedError:   true,
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+
+		output, err := RoleArnsFromTemplate(test.roleArnTemplate, PartitionForRegion(test.region), test.accountIDs)
+
+		assert.Equal(t, test.expectedValue, output)
+		assert.Equal(t, test.expectedError, err != nil)
+	}
+}
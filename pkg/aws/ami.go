@@ -6,14 +6,31 @@ import (
 	"strings"
 	"time"
 
-	awsLib "github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/ec2"
-	"github.com/aws/aws-sdk-go/service/eks"
-	"github.com/aws/aws-sdk-go/service/ssm"
+	awsLib "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
 	"github.com/rs/zerolog/log"
 )
 
-func GetLatestAmiWithinSsm(amiType, amiVersion, region string, awsSsm SSM, awsEc2 Ec2, ctx context.Context) (time.Time, *string, error) {
+type WaitOptions struct {
+	MinDelay time.Duration
+	MaxDelay time.Duration
+	Timeout  time.Duration
+}
+
+type AmiRecord struct {
+	ImageID         string    `json:"imageId"`
+	ImageLocation   string    `json:"imageLocation"`
+	Release         string    `json:"release"`
+	ReleaseVersion  string    `json:"releaseVersion,omitempty"`
+	PublishDate     time.Time `json:"publishDate"`
+	DeprecationTime time.Time `json:"deprecationTime"`
+}
+
+func GetAmiSsmParameterName(amiType, amiVersion string) (string, error) {
 	var ssmPath string
 
 	ssmBootlerocketPathPrefix := "/aws/service/bottlerocket/aws-k8s-"
@@ -47,139 +64,200 @@ func GetLatestAmiWithinSsm(amiType, amiVersion, region string, awsSsm SSM, awsEc
 	case "WINDOWS_FULL_2022_x86_64":
 		ssmPath = "/aws/service/ami-windows-latest/Windows_Server-2022-English-Full-EKS_Optimized-" + amiVersion + ssmPathSuffix
 	default:
-		return time.Time{}, nil, fmt.Errorf("nodegroup's ami type (%s) is not recognize", amiType)
+		return "", fmt.Errorf("nodegroup's ami type (%s) is not recognize", amiType)
+	}
+
+	return ssmPath, nil
+}
+
+func GetAmiSsmParameter(amiType, amiVersion, region string, awsSsm SSM, ctx context.Context) (*ssmTypes.Parameter, error) {
+	ssmPath, err := GetAmiSsmParameterName(amiType, amiVersion)
+	if err != nil {
+		return nil, err
 	}
 
-	output, err := awsSsm.GetParameter(&ssm.GetParameterInput{
+	output, err := awsSsm.GetParameter(ctx, &ssm.GetParameterInput{
 		Name:           &ssmPath,
 		WithDecryption: new(bool),
 	})
+	if ErrorCode(err) == "ParameterNotFound" {
+		return nil, parameterNotPublishedError(ssmPath, region, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return output.Parameter, nil
+}
+
+func parameterNotPublishedError(ssmPath, region string, err error) error {
+	return fmt.Errorf("ami parameter (%s) is not published in region %s of the %s partition: %w", ssmPath, region, PartitionForRegion(region).ID, err)
+}
+
+func GetLatestAmiWithinSsm(amiType, amiVersion, region string, awsSsm SSM, awsEc2 Ec2, ctx context.Context) (AmiRecord, error) {
+	parameter, err := GetAmiSsmParameter(amiType, amiVersion, region, awsSsm, ctx)
 	if err != nil {
-		return time.Time{}, nil, err
+		return AmiRecord{}, err
 	}
 
-	awsLatestAmiImageLocation, err := GetLatestAmiWithinEc2(*output.Parameter.Value, awsEc2, ctx)
+	image, err := GetLatestAmiWithinEc2(*parameter.Value, region, awsEc2, ctx)
 	if err != nil {
-		return time.Time{}, nil, err
+		return AmiRecord{}, err
 	}
 
-	return *output.Parameter.LastModifiedDate, awsLatestAmiImageLocation, nil
+	return NewAmiRecord(amiType, *parameter.Value, *parameter.LastModifiedDate, image)
 }
 
-func GetLatestAmiWithinEc2(amiVersion string, awsEc2 Ec2, ctx context.Context) (*string, error) {
+func GetLatestAmiWithinEc2(amiVersion, region string, awsEc2 Ec2, ctx context.Context) (*ec2Types.Image, error) {
 	input := &ec2.DescribeImagesInput{
-		ImageIds: []*string{
-			awsLib.String(amiVersion),
-		},
-		Owners: []*string{
-			awsLib.String("amazon"),
-		},
+		ImageIds: []string{amiVersion},
+		Owners:   PartitionForRegion(region).ImageOwners(region),
 	}
 
-	result, err := awsEc2.DescribeImages(input)
+	result, err := awsEc2.DescribeImages(ctx, input)
 	if err != nil {
 		return nil, err
 	}
+	if len(result.Images) == 0 {
+		return nil, fmt.Errorf("ami (%s) is not found", amiVersion)
+	}
 
-	return result.Images[0].ImageLocation, nil
+	return &result.Images[0], nil
 }
 
-func IsTheSameAmiVersion(nodegroup NodeGroup, ngAmiType, ngAmiVersion, ngAmiReleaseVersion string, awsSsm SSM, awsEc2 Ec2, ctx context.Context) (bool, error) {
-	var awsLatestAmiReleaseVersion string
+func NewAmiRecord(amiType, imageID string, publishDate time.Time, image *ec2Types.Image) (AmiRecord, error) {
+	var release, releaseVersion string
+	var deprecationTime time.Time
 
-	logWithContext := log.Ctx(ctx).With().Str("function", "IsTheSameAmiVersion").Logger()
+	// release version is the one eks takes for the update, it stays empty when it can not be told from the image
+	imageLocationSplites := strings.Split(*image.ImageLocation, "-")
+	last := len(imageLocationSplites)
+	switch strings.Split(amiType, "_")[0] {
+	case "BOTTLEROCKET":
+		release = imageLocationSplites[last-2] + "-" + imageLocationSplites[last-1]
+		releaseVersion = strings.TrimPrefix(release, "v")
+	case "AL2":
+		release = imageLocationSplites[last-1]
+		releaseVersion = amazonLinuxReleaseVersion(image, release)
+	case "WINDOWS":
+		release = imageLocationSplites[last-1]
+	default:
+		return AmiRecord{}, fmt.Errorf("nodegroup's ami type (%s) is not recognize", amiType)
+	}
 
-	_, awsLatestAmiImageLocation, err := GetLatestAmiWithinSsm(ngAmiType, ngAmiVersion, nodegroup.Region, awsSsm, awsEc2, ctx)
-	if err != nil {
-		return false, fmt.Errorf("region: %s, cluster: %s, nodegroup: %s : %w", nodegroup.Region, nodegroup.ClusterName, nodegroup.ClusterName, err)
+	if image.DeprecationTime != nil {
+		parsed, err := time.Parse(time.RFC3339, *image.DeprecationTime)
+		if err != nil {
+			return AmiRecord{}, fmt.Errorf("ami (%s) deprecation time (%s) can not be parsed: %w", imageID, *image.DeprecationTime, err)
+		}
+		deprecationTime = parsed.UTC()
+	}
+
+	return AmiRecord{
+		ImageID:         imageID,
+		ImageLocation:   *image.ImageLocation,
+		Release:         release,
+		ReleaseVersion:  releaseVersion,
+		PublishDate:     publishDate,
+		DeprecationTime: deprecationTime,
+	}, nil
+}
+
+func amazonLinuxReleaseVersion(image *ec2Types.Image, release string) string {
+	// the description tells the kubernetes patch version, e.g. "EKS Kubernetes Worker AMI with AmazonLinux2 image, (k8s: 1.28.5, containerd: 1.7.11)"
+	if image.Description == nil {
+		return ""
+	}
+	_, kubernetes, found := strings.Cut(*image.Description, "k8s: ")
+	if !found {
+		return ""
 	}
+	kubernetes, _, _ = strings.Cut(kubernetes, ",")
+	kubernetes, _, _ = strings.Cut(kubernetes, ")")
+
+	return strings.TrimSpace(kubernetes) + "-" + strings.TrimPrefix(release, "v")
+}
+
+func IsTheSameAmiVersion(nodegroup NodeGroup, ngAmiType, ngAmiReleaseVersion string, latestAmi AmiRecord, ctx context.Context) (bool, error) {
+	logWithContext := log.Ctx(ctx).With().Str("function", "IsTheSameAmiVersion").Logger()
 
-	awsLatestAmiImageLocationSplites := strings.Split(*awsLatestAmiImageLocation, "-")
-	last := len(awsLatestAmiImageLocationSplites)
 	switch strings.Split(ngAmiType, "_")[0] {
 	case "BOTTLEROCKET":
-		awsLatestAmiReleaseVersion = awsLatestAmiImageLocationSplites[last-2] + "-" + awsLatestAmiImageLocationSplites[last-1]
 		ngAmiReleaseVersion = "v" + ngAmiReleaseVersion
 	case "AL2", "WINDOWS":
-		awsLatestAmiReleaseVersion = awsLatestAmiImageLocationSplites[last-1]
 		ngAmiReleaseVersion = "v" + strings.Split(ngAmiReleaseVersion, "-")[1]
 	default:
 		return true, fmt.Errorf("nodegroup's ami type (%s) is not recognize", ngAmiType)
 	}
 
 	isTheSameAmiVersion := true
-	if awsLatestAmiReleaseVersion != ngAmiReleaseVersion {
+	if latestAmi.Release != ngAmiReleaseVersion {
 		isTheSameAmiVersion = false
 	}
 
-	logWithContext.Debug().Str("ngAmiReleaseVersion", ngAmiReleaseVersion).Str("awsLatestAmiReleaseVersion", awsLatestAmiReleaseVersion).
+	logWithContext.Debug().Str("ngAmiReleaseVersion", ngAmiReleaseVersion).Str("awsLatestAmiReleaseVersion", latestAmi.Release).
 		Str("region", nodegroup.Region).Str("nodegroup", nodegroup.NodegroupName).Str("cluster", nodegroup.ClusterName).Msg("nodegroup and aws latest ami versions are compared")
 
 	return isTheSameAmiVersion, nil
 }
 
-func IsLastAmiOldEnough(skipNewerThan uint, nodegroup NodeGroup, today time.Time, ngAmiType, ngAmiVersion string, awsSsm SSM, awsEc2 Ec2, ctx context.Context) (bool, error) {
+func IsLastAmiOldEnough(skipNewerThan uint, nodegroup NodeGroup, today time.Time, latestAmi AmiRecord, ctx context.Context) bool {
 	logWithContext := log.Ctx(ctx).With().Str("function", "IsLastAmiOldEnough").Logger()
 
-	amiLastModifiedDate, _, err := GetLatestAmiWithinSsm(ngAmiType, ngAmiVersion, nodegroup.Region, awsSsm, awsEc2, ctx)
-	if err != nil {
-		return false, fmt.Errorf("region: %s, cluster: %s, nodegroup: %s : %w", nodegroup.Region, nodegroup.ClusterName, nodegroup.ClusterName, err)
-	}
-
 	hoursToSkip := -24 * time.Duration(skipNewerThan) * time.Hour //nolint:gosec // no overflow risk
 	criticalDay := today.Add(hoursToSkip).UTC()
 
 	logWithContext.Debug().Time("criticalDay", criticalDay).Uint("skipNewerThan", skipNewerThan).
 		Str("region", nodegroup.Region).Str("nodegroup", nodegroup.NodegroupName).Str("cluster", nodegroup.ClusterName).Msg("ami criticalDay is calculated")
 
-	if amiLastModifiedDate.Before(criticalDay) {
-		return true, nil
-	}
-
-	return false, nil
+	return latestAmi.PublishDate.Before(criticalDay)
 }
 
-func AmiUpdate(region, cluster, nodegroup string, dryrun bool, ctx context.Context) error {
-	logWithContext := log.Ctx(ctx).With().Str("function", "AmiUpdate").Logger()
+func StartAmiUpdate(nodegroup NodeGroup, releaseVersion string, force bool, awsEks EKS, ctx context.Context) error {
+	logWithContext := log.Ctx(ctx).With().Str("function", "StartAmiUpdate").Logger()
 
-	if dryrun {
-		log.Info().Str("region", region).Str("cluster", cluster).Str("nodegroup", nodegroup).Msg("drying is true. exiting")
+	log.Info().Str("account", nodegroup.AccountID).Str("region", nodegroup.Region).Str("cluster", nodegroup.ClusterName).Str("nodegroup", nodegroup.NodegroupName).
+		Str("release", releaseVersion).Bool("force", force).Msg("starting ami update")
 
-		return nil
+	input := &eks.UpdateNodegroupVersionInput{
+		ClusterName:   awsLib.String(nodegroup.ClusterName),
+		NodegroupName: awsLib.String(nodegroup.NodegroupName),
+		Force:         force,
 	}
-
-	svcEks, err := EksClientSetup(region)
+	if releaseVersion != "" {
+		input.ReleaseVersion = awsLib.String(releaseVersion)
+	}
+	_, err := awsEks.UpdateNodegroupVersion(ctx, input)
 	if err != nil {
-		logWithContext.Debug().Str("region", region).Str("cluster", cluster).Str("nodegroup", nodegroup).Err(err).Msg("Error")
+		logWithContext.Debug().Str("region", nodegroup.Region).Str("cluster", nodegroup.ClusterName).Str("nodegroup", nodegroup.NodegroupName).Err(err).Msg("Error")
 
 		return err
 	}
+	logWithContext.Debug().Str("region", nodegroup.Region).Str("cluster", nodegroup.ClusterName).Str("nodegroup", nodegroup.NodegroupName).Msg("started properly")
 
-	log.Info().Str("region", region).Str("cluster", cluster).Str("nodegroup", nodegroup).Msg("starting ami update")
-
-	_, err = svcEks.UpdateNodegroupVersion(&eks.UpdateNodegroupVersionInput{
-		ClusterName:   awsLib.String(cluster),
-		NodegroupName: awsLib.String(nodegroup),
-	})
-	if err != nil {
-		logWithContext.Debug().Str("region", region).Str("cluster", cluster).Str("nodegroup", nodegroup).Err(err).Msg("Error")
+	return nil
+}
 
-		return err
-	}
-	logWithContext.Debug().Str("region", region).Str("cluster", cluster).Str("nodegroup", nodegroup).Msg("started properly")
+func WaitForAmiUpdate(nodegroup NodeGroup, awsEks EKS, waitOptions WaitOptions, ctx context.Context) error {
+	logWithContext := log.Ctx(ctx).With().Str("function", "WaitForAmiUpdate").Logger()
 
-	logWithContext.Debug().Str("region", region).Str("cluster", cluster).Str("nodegroup", nodegroup).Msg("waiting for finish")
-	err = svcEks.WaitUntilNodegroupActive(&eks.DescribeNodegroupInput{
-		ClusterName:   awsLib.String(cluster),
-		NodegroupName: awsLib.String(nodegroup),
+	logWithContext.Debug().Str("region", nodegroup.Region).Str("cluster", nodegroup.ClusterName).Str("nodegroup", nodegroup.NodegroupName).
+		Dur("timeout", waitOptions.Timeout).Msg("waiting for finish")
+	waiter := eks.NewNodegroupActiveWaiter(awsEks, func(o *eks.NodegroupActiveWaiterOptions) {
+		o.MinDelay = waitOptions.MinDelay
+		o.MaxDelay = waitOptions.MaxDelay
 	})
+	err := waiter.Wait(ctx, &eks.DescribeNodegroupInput{
+		ClusterName:   awsLib.String(nodegroup.ClusterName),
+		NodegroupName: awsLib.String(nodegroup.NodegroupName),
+	}, waitOptions.Timeout)
 	if err != nil {
-		logWithContext.Debug().Str("region", region).Str("cluster", cluster).Str("nodegroup", nodegroup).Err(err).Msg("Error")
+		logWithContext.Debug().Str("region", nodegroup.Region).Str("cluster", nodegroup.ClusterName).Str("nodegroup", nodegroup.NodegroupName).Err(err).Msg("Error")
 
 		return err
 	}
 
-	log.Info().Str("region", region).Str("cluster", cluster).Str("nodegroup", nodegroup).Msg("finished ami update properly")
+	log.Info().Str("account", nodegroup.AccountID).Str("region", nodegroup.Region).Str("cluster", nodegroup.ClusterName).Str("nodegroup", nodegroup.NodegroupName).Msg("finished ami update properly")
 
 	return nil
 }
@@ -6,9 +6,14 @@ import (
 	"testing"
 	"time"
 
-	awsLib "github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/ec2"
-	"github.com/aws/aws-sdk-go/service/ssm"
+	awsLib "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	eksTypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/smithy-go"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -36,13 +41,13 @@ func TestIsLastAmiOldEnough(t *testing.T) {
 			ngAmiType:    "BOTTLEROCKET_x86_64",
 			ngAmiVersion: "1.24",
 			mockedOutputGetParameterSsm: ssm.GetParameterOutput{
-				Parameter: &ssm.Parameter{
+				Parameter: &ssmTypes.Parameter{
 					LastModifiedDate: toTimePtr(time.Date(2023, time.January, 30, 10, 0, 0, 0, time.UTC)),
 					Value:            awsLib.String("ami-08a3df9f52daf9b5f"),
 				},
 			},
 			mockedOutputGetParameterEc2: ec2.DescribeImagesOutput{
-				Images: []*ec2.Image{
+				Images: []ec2Types.Image{
 					{
 						ImageLocation: awsLib.String("amazon/bottlerocket-aws-k8s-1.24-x86_64-v1.14.0-9cd59298"),
 					},
@@ -59,13 +64,13 @@ func TestIsLastAmiOldEnough(t *testing.T) {
 			ngAmiType:    "BOTTLEROCKET_x86_64",
 			ngAmiVersion: "1.24",
 			mockedOutputGetParameterSsm: ssm.GetParameterOutput{
-				Parameter: &ssm.Parameter{
+				Parameter: &ssmTypes.Parameter{
 					LastModifiedDate: toTimePtr(time.Date(2023, time.January, 30, 10, 0, 0, 0, time.UTC)),
 					Value:            awsLib.String("ami-08a3df9f52daf9b5f"),
 				},
 			},
 			mockedOutputGetParameterEc2: ec2.DescribeImagesOutput{
-				Images: []*ec2.Image{
+				Images: []ec2Types.Image{
 					{
 						ImageLocation: awsLib.String("amazon/bottlerocket-aws-k8s-1.24-x86_64-v1.14.0-9cd59298"),
 					},
@@ -82,13 +87,13 @@ func TestIsLastAmiOldEnough(t *testing.T) {
 			ngAmiType:    "BOTTLEROCKET_x86_64",
 			ngAmiVersion: "1.24",
 			mockedOutputGetParameterSsm: ssm.GetParameterOutput{
-				Parameter: &ssm.Parameter{
+				Parameter: &ssmTypes.Parameter{
 					LastModifiedDate: toTimePtr(time.Date(2023, time.January, 15, 9, 59, 0, 0, time.UTC)),
 					Value:            awsLib.String("ami-08a3df9f52daf9b5f"),
 				},
 			},
 			mockedOutputGetParameterEc2: ec2.DescribeImagesOutput{
-				Images: []*ec2.Image{
+				Images: []ec2Types.Image{
 					{
 						ImageLocation: awsLib.String("amazon/bottlerocket-aws-k8s-1.24-x86_64-v1.14.0-9cd59298"),
 					},
@@ -105,13 +110,13 @@ func TestIsLastAmiOldEnough(t *testing.T) {
 			ngAmiType:    "SOMETHINGNEW_x86_64",
 			ngAmiVersion: "1.24",
 			mockedOutputGetParameterSsm: ssm.GetParameterOutput{
-				Parameter: &ssm.Parameter{
+				Parameter: &ssmTypes.Parameter{
 					LastModifiedDate: toTimePtr(time.Date(2023, time.January, 30, 10, 0, 0, 0, time.UTC)),
 					Value:            awsLib.String("ami-08a3df9f52daf9b5f"),
 				},
 			},
 			mockedOutputGetParameterEc2: ec2.DescribeImagesOutput{
-				Images: []*ec2.Image{
+				Images: []ec2Types.Image{
 					{
 						ImageLocation: awsLib.String("amazon/bottlerocket-aws-k8s-1.24-x86_64-v1.14.0-9cd59298"),
 					},
@@ -121,20 +126,20 @@ func TestIsLastAmiOldEnough(t *testing.T) {
 			nodegroup:         NodeGroup{},
 			today:             time.Date(2023, time.February, 1, 10, 0, 0, 0, time.UTC),
 			expectedValue:     false,
-			expectedError:     fmt.Errorf("region: , cluster: , nodegroup:  : %w", fmt.Errorf("nodegroup's ami type (SOMETHINGNEW_x86_64) is not recognize")),
+			expectedError:     fmt.Errorf("nodegroup's ami type (SOMETHINGNEW_x86_64) is not recognize"),
 		},
 		{
 			name:         "AL2 ami type",
 			ngAmiType:    "AL2_x86_64",
 			ngAmiVersion: "1.28.",
 			mockedOutputGetParameterSsm: ssm.GetParameterOutput{
-				Parameter: &ssm.Parameter{
+				Parameter: &ssmTypes.Parameter{
 					LastModifiedDate: toTimePtr(time.Date(2023, time.January, 30, 10, 0, 0, 0, time.UTC)),
 					Value:            awsLib.String("ami-08a3df9f52daf9b5f"),
 				},
 			},
 			mockedOutputGetParameterEc2: ec2.DescribeImagesOutput{
-				Images: []*ec2.Image{
+				Images: []ec2Types.Image{
 					{
 						ImageLocation: awsLib.String("amazon/amazon-eks-node-1.28-v20240202"),
 					},
@@ -150,14 +155,217 @@ func TestIsLastAmiOldEnough(t *testing.T) {
 
 	for _, test := range tests {
 		fmt.Printf("test: %s\n", test.name)
-		awsSsm := testSsm{
+		awsSsm := TestSsm{
 			OutputGetParameter: &test.mockedOutputGetParameterSsm,
 		}
-		awsEc2 := testEc2{
+		awsEc2 := TestEc2{
 			OutputImages: &test.mockedOutputGetParameterEc2,
 		}
 
-		output, err := IsLastAmiOldEnough(test.skipNewerThanDays, test.nodegroup, test.today, test.ngAmiType, test.ngAmiVersion, awsSsm, awsEc2, context.Background())
+		latestAmi, err := GetLatestAmiWithinSsm(test.ngAmiType, test.ngAmiVersion, test.nodegroup.Region, awsSsm, awsEc2, context.Background())
+		output := err == nil && IsLastAmiOldEnough(test.skipNewerThanDays, test.nodegroup, test.today, latestAmi, context.Background())
+
+		assert.Equal(t, test.expectedValue, output)
+		assert.Equal(t, test.expectedError, err)
+	}
+}
+
+func TestStartAmiUpdate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		nodegroup      NodeGroup
+		releaseVersion string
+		force          bool
+		mockedError    error
+		expectedInput  eks.UpdateNodegroupVersionInput
+		expectedError  error
+	}{
+		{name: "update is started for the nodegroup",
+			nodegroup:     NodeGroup{Region: "eu-west-1", ClusterName: "cluster-1", NodegroupName: "ng-1"},
+			expectedInput: eks.UpdateNodegroupVersionInput{ClusterName: awsLib.String("cluster-1"), NodegroupName: awsLib.String("ng-1")},
+			expectedError: nil,
+		},
+		{name: "update is started with a pinned release and force",
+			nodegroup:      NodeGroup{Region: "eu-west-1", ClusterName: "cluster-1", NodegroupName: "ng-1"},
+			releaseVersion: "1.29.0-20240202",
+			force:          true,
+			expectedInput:  eks.UpdateNodegroupVersionInput{ClusterName: awsLib.String("cluster-1"), NodegroupName: awsLib.String("ng-1"), ReleaseVersion: awsLib.String("1.29.0-20240202"), Force: true},
+			expectedError:  nil,
+		},
+		{name: "update can not be started",
+			nodegroup:     NodeGroup{Region: "eu-west-1", ClusterName: "cluster-1", NodegroupName: "ng-1"},
+			mockedError:   &smithy.GenericAPIError{Code: "ResourceInUseException", Message: "update already in progress"},
+			expectedInput: eks.UpdateNodegroupVersionInput{ClusterName: awsLib.String("cluster-1"), NodegroupName: awsLib.String("ng-1")},
+			expectedError: &smithy.GenericAPIError{Code: "ResourceInUseException", Message: "update already in progress"},
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		var input eks.UpdateNodegroupVersionInput
+		awsEks := TestEks{InputUpdateNodegroup: &input, ErrUpdateNodegroup: test.mockedError}
+
+		err := StartAmiUpdate(test.nodegroup, test.releaseVersion, test.force, awsEks, context.Background())
+
+		assert.Equal(t, test.expectedInput, input)
+		assert.Equal(t, test.expectedError, err)
+	}
+}
+
+func TestWaitForAmiUpdate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		status        eksTypes.NodegroupStatus
+		mockedError   error
+		expectedError string
+	}{
+		{name: "nodegroup becomes active",
+			status:        eksTypes.NodegroupStatusActive,
+			expectedError: "",
+		},
+		{name: "nodegroup is still updating when the wait times out",
+			status:        eksTypes.NodegroupStatusUpdating,
+			expectedError: "exceeded max wait time for NodegroupActive waiter",
+		},
+		{name: "nodegroup can not be described",
+			mockedError:   &smithy.GenericAPIError{Code: "ResourceNotFoundException", Message: "no node group found"},
+			expectedError: "api error ResourceNotFoundException: no node group found",
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		awsEks := TestEks{
+			OutputDescribeNodegroup: &eks.DescribeNodegroupOutput{Nodegroup: &eksTypes.Nodegroup{Status: test.status}},
+			ErrDescribeNodegroup:    test.mockedError,
+		}
+		waitOptions := WaitOptions{MinDelay: 10 * time.Millisecond, MaxDelay: 10 * time.Millisecond, Timeout: 50 * time.Millisecond}
+
+		err := WaitForAmiUpdate(NodeGroup{Region: "eu-west-1", ClusterName: "cluster-1", NodegroupName: "ng-1"}, awsEks, waitOptions, context.Background())
+
+		if test.expectedError == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.EqualError(t, err, test.expectedError)
+		}
+	}
+}
+
+func TestIsTheSameAmiVersion(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                string
+		ngAmiType           string
+		ngAmiReleaseVersion string
+		latestAmi           AmiRecord
+		expectedValue       bool
+		expectedError       error
+	}{
+		{name: "bottlerocket nodegroup uses the latest ami",
+			ngAmiType:           "BOTTLEROCKET_x86_64",
+			ngAmiReleaseVersion: "1.14.0-9cd59298",
+			latestAmi:           AmiRecord{Release: "v1.14.0-9cd59298"},
+			expectedValue:       true,
+			expectedError:       nil,
+		},
+		{name: "bottlerocket nodegroup uses an older ami",
+			ngAmiType:           "BOTTLEROCKET_x86_64",
+			ngAmiReleaseVersion: "1.13.5-a1b2c3d4",
+			latestAmi:           AmiRecord{Release: "v1.14.0-9cd59298"},
+			expectedValue:       false,
+			expectedError:       nil,
+		},
+		{name: "al2 nodegroup uses the latest ami",
+			ngAmiType:           "AL2_x86_64",
+			ngAmiReleaseVersion: "1.28.5-20240202",
+			latestAmi:           AmiRecord{Release: "v20240202"},
+			expectedValue:       true,
+			expectedError:       nil,
+		},
+		{name: "unrecognize ami type",
+			ngAmiType:           "SOMETHINGNEW_x86_64",
+			ngAmiReleaseVersion: "1.28.5-20240202",
+			latestAmi:           AmiRecord{Release: "v20240202"},
+			expectedValue:       true,
+			expectedError:       fmt.Errorf("nodegroup's ami type (SOMETHINGNEW_x86_64) is not recognize"),
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+
+		output, err := IsTheSameAmiVersion(NodeGroup{}, test.ngAmiType, test.ngAmiReleaseVersion, test.latestAmi, context.Background())
+
+		assert.Equal(t, test.expectedValue, output)
+		assert.Equal(t, test.expectedError, err)
+	}
+}
+
+func TestNewAmiRecord(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		amiType       string
+		image         ec2Types.Image
+		expectedValue AmiRecord
+		expectedError error
+	}{
+		{name: "bottlerocket ami with deprecation time",
+			amiType: "BOTTLEROCKET_ARM_64",
+			image: ec2Types.Image{
+				ImageLocation:   awsLib.String("amazon/bottlerocket-aws-k8s-1.24-aarch64-v1.14.0-9cd59298"),
+				DeprecationTime: awsLib.String("2025-01-30T10:00:00.000Z"),
+			},
+			expectedValue: AmiRecord{
+				ImageID:         "ami-08a3df9f52daf9b5f",
+				ImageLocation:   "amazon/bottlerocket-aws-k8s-1.24-aarch64-v1.14.0-9cd59298",
+				Release:         "v1.14.0-9cd59298",
+				ReleaseVersion:  "1.14.0-9cd59298",
+				PublishDate:     time.Date(2023, time.January, 30, 10, 0, 0, 0, time.UTC),
+				DeprecationTime: time.Date(2025, time.January, 30, 10, 0, 0, 0, time.UTC),
+			},
+			expectedError: nil,
+		},
+		{name: "amazon linux ami with kubernetes version in description",
+			amiType: "AL2_x86_64",
+			image: ec2Types.Image{
+				ImageLocation: awsLib.String("amazon/amazon-eks-node-1.28-v20240202"),
+				Description:   awsLib.String("EKS Kubernetes Worker AMI with AmazonLinux2 image, (k8s: 1.28.5, containerd: 1.7.11-1.amzn2.0.1)"),
+			},
+			expectedValue: AmiRecord{
+				ImageID:        "ami-08a3df9f52daf9b5f",
+				ImageLocation:  "amazon/amazon-eks-node-1.28-v20240202",
+				Release:        "v20240202",
+				ReleaseVersion: "1.28.5-20240202",
+				PublishDate:    time.Date(2023, time.January, 30, 10, 0, 0, 0, time.UTC),
+			},
+			expectedError: nil,
+		},
+		{name: "windows ami without deprecation time",
+			amiType: "WINDOWS_CORE_2022_x86_64",
+			image: ec2Types.Image{
+				ImageLocation: awsLib.String("amazon/Windows_Server-2022-English-Core-EKS_Optimized-1.28-2024.02.13"),
+			},
+			expectedValue: AmiRecord{
+				ImageID:       "ami-08a3df9f52daf9b5f",
+				ImageLocation: "amazon/Windows_Server-2022-English-Core-EKS_Optimized-1.28-2024.02.13",
+				Release:       "2024.02.13",
+				PublishDate:   time.Date(2023, time.January, 30, 10, 0, 0, 0, time.UTC),
+			},
+			expectedError: nil,
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+
+		output, err := NewAmiRecord(test.amiType, "ami-08a3df9f52daf9b5f", time.Date(2023, time.January, 30, 10, 0, 0, 0, time.UTC), &test.image)
 
 		assert.Equal(t, test.expectedValue, output)
 		assert.Equal(t, test.expectedError, err)
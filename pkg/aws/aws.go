@@ -1,42 +1,166 @@
 package aws
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"sync"
 
-	awsLib "github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/ec2"
-	"github.com/aws/aws-sdk-go/service/eks"
-	"github.com/aws/aws-sdk-go/service/ssm"
+	awsLib "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
 )
 
-func EksClientSetup(awsRegion string) (*eks.EKS, error) {
-	session, err := session.NewSession(&awsLib.Config{
-		Region: awsLib.String(awsRegion)},
-	)
+const fallbackRegion = "us-east-1"
+
+type Clients interface {
+	DefaultRegion() string
+	AccountID(ctx context.Context) (string, error)
+	AssumeRole(roleArn, externalID string, ctx context.Context) (Clients, error)
+	Eks(region string) (EKS, error)
+	Ssm(region string) (SSM, error)
+	Ec2(region string) (Ec2, error)
+	Organizations() (Organizations, error)
+}
+
+type RealClients struct {
+	config        awsLib.Config
+	endpoints     EndpointOptions
+	accountID     string
+	throttler     *Throttler
+	mutex         sync.Mutex
+	eks           map[string]EKS
+	ssm           map[string]SSM
+	ec2           map[string]Ec2
+	organizations Organizations
+}
+
+func NewRealClients(throttler *Throttler, endpoints EndpointOptions, ctx context.Context) (*RealClients, error) {
+	loadOptions, err := endpoints.loadOptions()
 	if err != nil {
-		return nil, fmt.Errorf("error creating new Eks session in %s region: %w", awsRegion, err)
+		return nil, err
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(ctx, loadOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("error loading aws config: %w", err)
+	}
+	if awsConfig.Region == "" {
+		awsConfig.Region = defaultRegion()
 	}
 
-	return eks.New(session), nil
+	return newRealClients(awsConfig, endpoints, "", throttler), nil
 }
 
-func SsmClientSetup(awsRegion string) (*ssm.SSM, error) {
-	session, err := session.NewSession(&awsLib.Config{
-		Region: awsLib.String(awsRegion)},
-	)
-	if err != nil {
-		return nil, fmt.Errorf("error creating new Ssm session in %s region: %w", awsRegion, err)
+func defaultRegion() string {
+	if region := os.Getenv("AWS_DEFAULT_REGION"); region != "" {
+		return region
+	}
+
+	// web identity role (eg. IRSA) tells the partition when no region is configured
+	if partition, err := PartitionFromArn(os.Getenv("AWS_ROLE_ARN")); err == nil {
+		return partition.DefaultRegion
 	}
 
-	return ssm.New(session), nil
+	return fallbackRegion
 }
 
-func Ec2ClientSetup() (*ec2.EC2, error) {
-	session, err := session.NewSession(&awsLib.Config{})
-	if err != nil {
-		return nil, fmt.Errorf("error creating new Ec2 session: %w", err)
+func newRealClients(awsConfig awsLib.Config, endpoints EndpointOptions, accountID string, throttler *Throttler) *RealClients {
+	return &RealClients{
+		config:    awsConfig,
+		endpoints: endpoints,
+		accountID: accountID,
+		throttler: throttler,
+		eks:       map[string]EKS{},
+		ssm:       map[string]SSM{},
+		ec2:       map[string]Ec2{},
+	}
+}
+
+func (c *RealClients) DefaultRegion() string {
+	return c.config.Region
+}
+
+func (c *RealClients) Eks(region string) (EKS, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.eks[region]; !ok {
+		c.eks[region] = RealEks{
+			Svc: eks.NewFromConfig(c.config, func(o *eks.Options) {
+				o.Region = region
+				setRegionalBaseEndpoint(&o.BaseEndpoint, c.endpoints.Eks, region)
+				// the throttler retries the calls of the clients it wraps, sts and credential providers keep the sdk retries
+				o.Retryer = awsLib.NopRetryer{}
+			}),
+			Account:   c.accountID,
+			Region:    region,
+			Throttler: c.throttler,
+		}
+	}
+
+	return c.eks[region], nil
+}
+
+func (c *RealClients) Ssm(region string) (SSM, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.ssm[region]; !ok {
+		c.ssm[region] = RealSsm{
+			Svc: ssm.NewFromConfig(c.config, func(o *ssm.Options) {
+				o.Region = region
+				setRegionalBaseEndpoint(&o.BaseEndpoint, c.endpoints.Ssm, region)
+				o.Retryer = awsLib.NopRetryer{}
+			}),
+			Account:   c.accountID,
+			Region:    region,
+			Throttler: c.throttler,
+		}
+	}
+
+	return c.ssm[region], nil
+}
+
+func (c *RealClients) Ec2(region string) (Ec2, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, ok := c.ec2[region]; !ok {
+		c.ec2[region] = RealEc2{
+			Svc: ec2.NewFromConfig(c.config, func(o *ec2.Options) {
+				o.Region = region
+				setRegionalBaseEndpoint(&o.BaseEndpoint, c.endpoints.Ec2, region)
+				o.Retryer = awsLib.NopRetryer{}
+			}),
+			Account:   c.accountID,
+			Region:    region,
+			Throttler: c.throttler,
+		}
+	}
+
+	return c.ec2[region], nil
+}
+
+func (c *RealClients) Organizations() (Organizations, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// organizations is a global service, its endpoint does not depend on the region
+	if c.organizations == nil {
+		c.organizations = RealOrganizations{
+			Svc: organizations.NewFromConfig(c.config, func(o *organizations.Options) {
+				setBaseEndpoint(&o.BaseEndpoint, c.endpoints.Organizations)
+				o.Retryer = awsLib.NopRetryer{}
+			}),
+			Account:   c.accountID,
+			Region:    c.config.Region,
+			Throttler: c.throttler,
+		}
 	}
 
-	return ec2.New(session), nil
+	return c.organizations, nil
 }
@@ -0,0 +1,183 @@
+package aws
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	awsLib "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRealClients(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		regions         []string
+		expectedClients int
+	}{
+		{name: "clients are reused within a region",
+			regions:         []string{"eu-west-1", "eu-west-1", "eu-west-1"},
+			expectedClients: 1,
+		},
+		{name: "one client per region",
+			regions:         []string{"eu-west-1", "us-west-2", "eu-west-1"},
+			expectedClients: 2,
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		throttler := NewThrottler(RetryOptions{})
+		clients, err := NewRealClients(throttler, EndpointOptions{}, context.Background())
+		assert.NoError(t, err)
+
+		for _, region := range test.regions {
+			awsEks, err := clients.Eks(region)
+			assert.NoError(t, err)
+			awsSsm, err := clients.Ssm(region)
+			assert.NoError(t, err)
+			awsEc2, err := clients.Ec2(region)
+			assert.NoError(t, err)
+
+			cachedEks, _ := clients.Eks(region)
+			cachedSsm, _ := clients.Ssm(region)
+			cachedEc2, _ := clients.Ec2(region)
+			assert.Same(t, awsEks.(RealEks).Svc, cachedEks.(RealEks).Svc)
+			assert.Same(t, awsSsm.(RealSsm).Svc, cachedSsm.(RealSsm).Svc)
+			assert.Same(t, awsEc2.(RealEc2).Svc, cachedEc2.(RealEc2).Svc)
+			assert.Equal(t, region, awsEc2.(RealEc2).Svc.Options().Region)
+			assert.Equal(t, 1, awsEc2.(RealEc2).Svc.Options().Retryer.MaxAttempts())
+			assert.Equal(t, 1, awsEks.(RealEks).Svc.Options().Retryer.MaxAttempts())
+			assert.Equal(t, 1, awsSsm.(RealSsm).Svc.Options().Retryer.MaxAttempts())
+			assert.Equal(t, region, awsSsm.(RealSsm).Region)
+			assert.Same(t, throttler, awsEks.(RealEks).Throttler)
+		}
+
+		// sts calls, eg. of assumed role credentials, do not go through the throttler and keep the sdk retries
+		awsOrganizations, _ := clients.Organizations()
+		assert.Equal(t, 1, awsOrganizations.(RealOrganizations).Svc.Options().Retryer.MaxAttempts())
+		assert.Greater(t, clients.sts().Options().Retryer.MaxAttempts(), 1)
+		assert.Len(t, clients.eks, test.expectedClients)
+		assert.Len(t, clients.ssm, test.expectedClients)
+		assert.Len(t, clients.ec2, test.expectedClients)
+	}
+}
+
+func TestRealClientsEndpoints(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name             string
+		endpoints        EndpointOptions
+		expectedEndpoint *string
+		expectedFIPS     awsLib.FIPSEndpointState
+		expectedError    error
+	}{
+		{name: "default endpoints",
+			endpoints:        EndpointOptions{},
+			expectedEndpoint: nil,
+			expectedFIPS:     awsLib.FIPSEndpointStateUnset,
+		},
+		{name: "custom endpoints",
+			endpoints:        EndpointOptions{Eks: "http://localhost:4566", Ssm: "http://localhost:4566", Ec2: "http://localhost:4566", Sts: "http://localhost:4566", Organizations: "http://localhost:4566", Regions: []string{"eu-west-1"}},
+			expectedEndpoint: awsLib.String("http://localhost:4566"),
+			expectedFIPS:     awsLib.FIPSEndpointStateUnset,
+		},
+		{name: "fips endpoints",
+			endpoints:        EndpointOptions{UseFIPS: true},
+			expectedEndpoint: nil,
+			expectedFIPS:     awsLib.FIPSEndpointStateEnabled,
+		},
+		{name: "fips endpoints with custom endpoint",
+			endpoints:     EndpointOptions{UseFIPS: true, Eks: "http://localhost:4566", Regions: []string{"eu-west-1"}},
+			expectedError: ErrEndpointConflict,
+		},
+		{name: "regional endpoint without region placeholder for every region",
+			endpoints:     EndpointOptions{Ec2: "http://localhost:4566"},
+			expectedError: ErrRegionalEndpoint,
+		},
+		{name: "regional endpoint without region placeholder for several regions",
+			endpoints:     EndpointOptions{Ssm: "http://localhost:4566", Regions: []string{"eu-west-1", "us-west-2"}},
+			expectedError: ErrRegionalEndpoint,
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+
+		clients, err := NewRealClients(nil, test.endpoints, context.Background())
+
+		assert.ErrorIs(t, err, test.expectedError)
+		if test.expectedError != nil {
+			continue
+		}
+		awsEks, _ := clients.Eks("eu-west-1")
+		awsSsm, _ := clients.Ssm("eu-west-1")
+		awsEc2, _ := clients.Ec2("eu-west-1")
+		awsOrganizations, _ := clients.Organizations()
+		assert.Equal(t, test.expectedEndpoint, awsEks.(RealEks).Svc.Options().BaseEndpoint)
+		assert.Equal(t, test.expectedEndpoint, awsSsm.(RealSsm).Svc.Options().BaseEndpoint)
+		assert.Equal(t, test.expectedEndpoint, awsEc2.(RealEc2).Svc.Options().BaseEndpoint)
+		assert.Equal(t, test.expectedEndpoint, awsOrganizations.(RealOrganizations).Svc.Options().BaseEndpoint)
+		assert.Equal(t, test.expectedEndpoint, clients.sts().Options().BaseEndpoint)
+		assert.Equal(t, test.expectedFIPS, awsEks.(RealEks).Svc.Options().EndpointOptions.UseFIPSEndpoint)
+	}
+}
+
+func TestRealClientsRegionalEndpoints(t *testing.T) {
+	t.Parallel()
+
+	clients, err := NewRealClients(nil, EndpointOptions{
+		Eks: "https://vpce-1.eks.{{.Region}}.vpce.amazonaws.com",
+		Ssm: "https://vpce-2.ssm.{{.Region}}.vpce.amazonaws.com",
+		Ec2: "https://vpce-3.ec2.{{.Region}}.vpce.amazonaws.com",
+	}, context.Background())
+	assert.NoError(t, err)
+
+	for _, region := range []string{"eu-west-1", "us-west-2"} {
+		fmt.Printf("test: endpoints of %s\n", region)
+		awsEks, _ := clients.Eks(region)
+		awsSsm, _ := clients.Ssm(region)
+		awsEc2, _ := clients.Ec2(region)
+
+		assert.Equal(t, "https://vpce-1.eks."+region+".vpce.amazonaws.com", *awsEks.(RealEks).Svc.Options().BaseEndpoint)
+		assert.Equal(t, "https://vpce-2.ssm."+region+".vpce.amazonaws.com", *awsSsm.(RealSsm).Svc.Options().BaseEndpoint)
+		assert.Equal(t, "https://vpce-3.ec2."+region+".vpce.amazonaws.com", *awsEc2.(RealEc2).Svc.Options().BaseEndpoint)
+	}
+}
+
+func TestRealClientsCABundle(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"clusters":["cluster-1"]}`))
+	}))
+	defer server.Close()
+
+	caBundle := filepath.Join(t.TempDir(), "ca.pem")
+	err := os.WriteFile(caBundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600)
+	assert.NoError(t, err)
+
+	_, err = NewRealClients(nil, EndpointOptions{CABundle: filepath.Join(t.TempDir(), "missing.pem")}, context.Background())
+	assert.Error(t, err)
+
+	clients, err := NewRealClients(nil, EndpointOptions{Eks: server.URL, CABundle: caBundle, Regions: []string{"eu-west-1"}}, context.Background())
+	assert.NoError(t, err)
+	clients.config.Credentials = credentials.NewStaticCredentialsProvider("key", "secret", "")
+
+	awsEks, err := clients.Eks("eu-west-1")
+	assert.NoError(t, err)
+	output, err := GetClusters("eu-west-1", awsEks, context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cluster-1"}, output)
+}
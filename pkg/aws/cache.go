@@ -0,0 +1,157 @@
+package aws
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+const amiCacheFormatVersion = 2
+
+type AmiCache struct {
+	path    string
+	ttl     time.Duration
+	now     func() time.Time
+	mutex   sync.Mutex
+	entries map[AmiKey]amiCacheEntry
+}
+
+type amiCacheFile struct {
+	FormatVersion int             `json:"formatVersion"`
+	Entries       []amiCacheEntry `json:"entries"`
+}
+
+type amiCacheEntry struct {
+	Key              AmiKey    `json:"key"`
+	ParameterVersion int64     `json:"parameterVersion"`
+	Record           AmiRecord `json:"record"`
+	CachedAt         time.Time `json:"cachedAt"`
+}
+
+func LoadAmiCache(path string, ttl time.Duration) (*AmiCache, error) {
+	var file amiCacheFile
+
+	cache := &AmiCache{
+		path:    path,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: map[AmiKey]amiCacheEntry{},
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return cache, fmt.Errorf("error reading ami cache file %s: %w", path, err)
+	}
+
+	err = json.Unmarshal(data, &file)
+	if err != nil {
+		return cache, fmt.Errorf("error parsing ami cache file %s: %w", path, err)
+	}
+	if file.FormatVersion != amiCacheFormatVersion {
+		return cache, nil
+	}
+
+	for _, entry := range file.Entries {
+		cache.entries[entry.Key] = entry
+	}
+
+	return cache, nil
+}
+
+func (c *AmiCache) Get(key AmiKey, parameter *ssmTypes.Parameter) (AmiRecord, bool) {
+	if c == nil {
+		return AmiRecord{}, false
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return AmiRecord{}, false
+	}
+	if c.now().Sub(entry.CachedAt) > c.ttl || entry.ParameterVersion != parameter.Version || entry.Record.ImageID != *parameter.Value {
+		delete(c.entries, key)
+
+		return AmiRecord{}, false
+	}
+
+	return entry.Record, true
+}
+
+func (c *AmiCache) Put(key AmiKey, parameter *ssmTypes.Parameter, record AmiRecord) {
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.entries[key] = amiCacheEntry{
+		Key:              key,
+		ParameterVersion: parameter.Version,
+		Record:           record,
+		CachedAt:         c.now().UTC(),
+	}
+}
+
+func (c *AmiCache) Save() error {
+	if c == nil {
+		return nil
+	}
+
+	c.mutex.Lock()
+	file := amiCacheFile{FormatVersion: amiCacheFormatVersion}
+	for _, entry := range c.entries {
+		file.Entries = append(file.Entries, entry)
+	}
+	c.mutex.Unlock()
+
+	sort.Slice(file.Entries, func(i, j int) bool {
+		a, b := file.Entries[i].Key, file.Entries[j].Key
+		if a.Region != b.Region {
+			return a.Region < b.Region
+		}
+		if a.AmiType != b.AmiType {
+			return a.AmiType < b.AmiType
+		}
+
+		return a.Version < b.Version
+	})
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding ami cache: %w", err)
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error writing ami cache file %s: %w", c.path, err)
+	}
+	defer os.Remove(temp.Name())
+
+	_, err = temp.Write(data)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error writing ami cache file %s: %w", c.path, err)
+	}
+
+	err = os.Rename(temp.Name(), c.path)
+	if err != nil {
+		return fmt.Errorf("error writing ami cache file %s: %w", c.path, err)
+	}
+
+	return nil
+}
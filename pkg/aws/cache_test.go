@@ -0,0 +1,135 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	awsLib "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAmiCacheGet(t *testing.T) {
+	t.Parallel()
+
+	key := AmiKey{Region: "eu-west-1", AmiType: "BOTTLEROCKET_x86_64", Version: "1.28"}
+	record := AmiRecord{ImageID: "ami-1", Release: "v1.19.0-c4d76236", PublishDate: time.Date(2024, time.February, 2, 10, 0, 0, 0, time.UTC)}
+	cachedAt := time.Date(2024, time.February, 3, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		parameter     ssmTypes.Parameter
+		now           time.Time
+		expectedValue AmiRecord
+		expectedHit   bool
+	}{
+		{name: "entry is fresh and parameter version is not changed",
+			parameter:     ssmTypes.Parameter{Value: awsLib.String("ami-1"), Version: 7},
+			now:           cachedAt.Add(time.Hour),
+			expectedValue: record,
+			expectedHit:   true,
+		},
+		{name: "parameter version is changed",
+			parameter:     ssmTypes.Parameter{Value: awsLib.String("ami-2"), Version: 8},
+			now:           cachedAt.Add(time.Hour),
+			expectedValue: AmiRecord{},
+			expectedHit:   false,
+		},
+		{name: "entry is older than ttl",
+			parameter:     ssmTypes.Parameter{Value: awsLib.String("ami-1"), Version: 7},
+			now:           cachedAt.Add(25 * time.Hour),
+			expectedValue: AmiRecord{},
+			expectedHit:   false,
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		cache, err := LoadAmiCache(filepath.Join(t.TempDir(), "amis.json"), 24*time.Hour)
+		assert.NoError(t, err)
+		cache.now = func() time.Time { return cachedAt }
+		cache.Put(key, &ssmTypes.Parameter{Value: awsLib.String("ami-1"), Version: 7}, record)
+		cache.now = func() time.Time { return test.now }
+
+		output, hit := cache.Get(key, &test.parameter)
+
+		assert.Equal(t, test.expectedValue, output)
+		assert.Equal(t, test.expectedHit, hit)
+	}
+}
+
+func TestAmiCacheSaveAndLoad(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "amis.json")
+	key := AmiKey{Region: "eu-west-1", AmiType: "AL2_x86_64", Version: "1.28"}
+	parameter := ssmTypes.Parameter{Value: awsLib.String("ami-2"), Version: 3}
+	record := AmiRecord{ImageID: "ami-2", Release: "v20240202", PublishDate: time.Date(2024, time.February, 2, 10, 0, 0, 0, time.UTC)}
+
+	cache, err := LoadAmiCache(path, time.Hour)
+	assert.NoError(t, err)
+	cache.Put(key, &parameter, record)
+	assert.NoError(t, cache.Save())
+
+	loaded, err := LoadAmiCache(path, time.Hour)
+	assert.NoError(t, err)
+	output, hit := loaded.Get(key, &parameter)
+	assert.True(t, hit)
+	assert.Equal(t, record, output)
+
+	assert.NoError(t, os.WriteFile(path, []byte("not json"), 0o600))
+	corrupted, err := LoadAmiCache(path, time.Hour)
+	assert.Error(t, err)
+	_, hit = corrupted.Get(key, &parameter)
+	assert.False(t, hit)
+}
+
+func TestAmiResolverWithCache(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "amis.json")
+	key := AmiKey{Region: "eu-west-1", AmiType: "BOTTLEROCKET_x86_64", Version: "1.24"}
+	var records []AmiRecord
+	var ec2CallsPerRun []int32
+
+	for range 2 {
+		var ssmCalls, ec2Calls atomic.Int32
+		cache, err := LoadAmiCache(path, time.Hour)
+		assert.NoError(t, err)
+		clients := TestClients{
+			AwsSsm: TestSsm{
+				OutputGetParameter: &ssm.GetParameterOutput{Parameter: &ssmTypes.Parameter{
+					LastModifiedDate: toTimePtr(time.Date(2023, time.January, 30, 10, 0, 0, 0, time.UTC)),
+					Value:            awsLib.String("ami-08a3df9f52daf9b5f"),
+					Version:          12,
+				}},
+				Calls: &ssmCalls,
+			},
+			AwsEc2: TestEc2{
+				OutputImages: &ec2.DescribeImagesOutput{Images: []ec2Types.Image{
+					{ImageLocation: awsLib.String("amazon/bottlerocket-aws-k8s-1.24-x86_64-v1.14.0-9cd59298")},
+				}},
+				Calls: &ec2Calls,
+			},
+		}
+		resolver := NewAmiResolver(cache)
+
+		record, err := resolver.Resolve(key, clients, context.Background())
+		assert.NoError(t, err)
+		assert.NoError(t, cache.Save())
+
+		records = append(records, record)
+		ec2CallsPerRun = append(ec2CallsPerRun, ec2Calls.Load())
+	}
+
+	assert.Equal(t, records[0], records[1])
+	assert.Equal(t, []int32{1, 0}, ec2CallsPerRun)
+}
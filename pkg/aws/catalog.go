@@ -0,0 +1,167 @@
+package aws
+
+import (
+	"context"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/smithy-go"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	maxImageIdsPerCall       = 200
+	maxParameterNamesPerCall = 10
+)
+
+// GetParametersByNames reads parameters by name rather than with a GetParametersByPath sweep,
+// which pages by 10 through every version and release of the ami trees.
+func GetParametersByNames(names []string, awsSsm SSM, ctx context.Context) (map[string]*ssmTypes.Parameter, error) {
+	parameters := map[string]*ssmTypes.Parameter{}
+	var missing []string
+	logWithContext := log.Ctx(ctx).With().Str("function", "GetParametersByNames").Logger()
+
+	for start := 0; start < len(names); start += maxParameterNamesPerCall {
+		end := min(start+maxParameterNamesPerCall, len(names))
+
+		output, err := awsSsm.GetParameters(ctx, &ssm.GetParametersInput{
+			Names:          names[start:end],
+			WithDecryption: new(bool),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, parameter := range output.Parameters {
+			parameters[*parameter.Name] = &parameter
+		}
+		missing = append(missing, output.InvalidParameters...)
+	}
+
+	logWithContext.Debug().Strs("names", names).Int("parameters", len(parameters)).Strs("missing", missing).Msg("parameters have been fetched")
+
+	return parameters, nil
+}
+
+func GetImagesByIds(imageIds []string, region string, awsEc2 Ec2, ctx context.Context) (map[string]*ec2Types.Image, error) {
+	images := map[string]*ec2Types.Image{}
+	logWithContext := log.Ctx(ctx).With().Str("function", "GetImagesByIds").Logger()
+
+	for start := 0; start < len(imageIds); start += maxImageIdsPerCall {
+		end := min(start+maxImageIdsPerCall, len(imageIds))
+
+		result, err := awsEc2.DescribeImages(ctx, &ec2.DescribeImagesInput{
+			ImageIds: imageIds[start:end],
+			Owners:   PartitionForRegion(region).ImageOwners(region),
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, image := range result.Images {
+			images[*image.ImageId] = &image
+		}
+	}
+
+	logWithContext.Debug().Strs("imageIds", imageIds).Int("images", len(images)).Msg("images have been described")
+
+	return images, nil
+}
+
+func (r *AmiResolver) Prefetch(region string, keys []AmiKey, clients Clients, ctx context.Context) error {
+	logWithContext := log.Ctx(ctx).With().Str("function", "Prefetch").Logger()
+
+	parameterNames := map[AmiKey]string{}
+	namesSet := map[string]bool{}
+	for _, key := range keys {
+		if key.Region != region {
+			continue
+		}
+		parameterName, err := GetAmiSsmParameterName(key.AmiType, key.Version)
+		if err != nil {
+			continue
+		}
+		parameterNames[key] = parameterName
+		namesSet[parameterName] = true
+	}
+	if len(parameterNames) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(namesSet))
+	for name := range namesSet {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	awsSsm, err := clients.Ssm(region)
+	if err != nil {
+		return err
+	}
+	parameters, err := GetParametersByNames(names, awsSsm, ctx)
+	if err != nil {
+		return err
+	}
+
+	imageIdsSet := map[string]bool{}
+	for key, parameterName := range parameterNames {
+		parameter, ok := parameters[parameterName]
+		if !ok {
+			// ssm has answered for the parameter, so a miss is final and is not looked up again one by one
+			logWithContext.Warn().Str("region", region).Str("parameter", parameterName).Msg("ami parameter is not published")
+			r.store(key, AmiRecord{}, parameterNotPublishedError(parameterName, region, &smithy.GenericAPIError{Code: "ParameterNotFound", Message: "parameter is not returned by GetParameters"}))
+			delete(parameterNames, key)
+
+			continue
+		}
+		if record, ok := r.cache.Get(key, parameter); ok {
+			r.store(key, record, nil)
+			delete(parameterNames, key)
+
+			continue
+		}
+		imageIdsSet[*parameter.Value] = true
+	}
+	if len(imageIdsSet) == 0 {
+		logWithContext.Debug().Str("region", region).Int("parameters", len(parameters)).Msg("ami catalog has been prefetched from cache")
+
+		return nil
+	}
+	imageIds := make([]string, 0, len(imageIdsSet))
+	for imageId := range imageIdsSet {
+		imageIds = append(imageIds, imageId)
+	}
+	sort.Strings(imageIds)
+
+	awsEc2, err := clients.Ec2(region)
+	if err != nil {
+		return err
+	}
+	images, err := GetImagesByIds(imageIds, region, awsEc2, ctx)
+	if err != nil {
+		return err
+	}
+
+	for key, parameterName := range parameterNames {
+		parameter := parameters[parameterName]
+		image, ok := images[*parameter.Value]
+		if !ok {
+			logWithContext.Debug().Str("region", region).Str("imageId", *parameter.Value).Msg("image is not found in catalog, it will be resolved separately")
+
+			continue
+		}
+		record, err := NewAmiRecord(key.AmiType, *parameter.Value, *parameter.LastModifiedDate, image)
+		if err != nil {
+			logWithContext.Debug().Str("region", region).Str("imageId", *parameter.Value).Err(err).Msg("image can not be read from catalog, it will be resolved separately")
+
+			continue
+		}
+		r.cache.Put(key, parameter, record)
+		r.store(key, record, nil)
+	}
+
+	logWithContext.Debug().Str("region", region).Int("parameters", len(parameters)).Int("images", len(images)).Msg("ami catalog has been prefetched")
+
+	return nil
+}
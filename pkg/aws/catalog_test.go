@@ -0,0 +1,119 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	awsLib "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAmiResolverPrefetch(t *testing.T) {
+	t.Parallel()
+
+	publishDate := time.Date(2024, time.February, 2, 10, 0, 0, 0, time.UTC)
+	mockedParameters := map[string]ssmTypes.Parameter{
+		"/aws/service/bottlerocket/aws-k8s-1.28/x86_64/latest/image_id": {
+			Name: awsLib.String("/aws/service/bottlerocket/aws-k8s-1.28/x86_64/latest/image_id"), Value: awsLib.String("ami-1"), LastModifiedDate: &publishDate,
+		},
+		"/aws/service/eks/optimized-ami/1.28/amazon-linux-2/recommended/image_id": {
+			Name: awsLib.String("/aws/service/eks/optimized-ami/1.28/amazon-linux-2/recommended/image_id"), Value: awsLib.String("ami-2"), LastModifiedDate: &publishDate,
+		},
+	}
+	mockedImages := ec2.DescribeImagesOutput{Images: []ec2Types.Image{
+		{ImageId: awsLib.String("ami-1"), ImageLocation: awsLib.String("amazon/bottlerocket-aws-k8s-1.28-x86_64-v1.19.0-c4d76236")},
+		{ImageId: awsLib.String("ami-2"), ImageLocation: awsLib.String("amazon/amazon-eks-node-1.28-v20240202")},
+	}}
+
+	tests := []struct {
+		name             string
+		keys             []AmiKey
+		expectedValue    []AmiRecord
+		expectedErrors   []string
+		expectedSsmCalls int32
+		expectedEc2Calls int32
+	}{
+		{name: "amis are resolved from the prefetched catalog",
+			keys: []AmiKey{
+				{Region: "eu-west-1", AmiType: "BOTTLEROCKET_x86_64", Version: "1.28"},
+				{Region: "eu-west-1", AmiType: "AL2_x86_64", Version: "1.28"},
+				{Region: "eu-west-1", AmiType: "BOTTLEROCKET_x86_64", Version: "1.28"},
+			},
+			expectedValue: []AmiRecord{
+				{ImageID: "ami-1", ImageLocation: "amazon/bottlerocket-aws-k8s-1.28-x86_64-v1.19.0-c4d76236", Release: "v1.19.0-c4d76236", ReleaseVersion: "1.19.0-c4d76236", PublishDate: publishDate},
+				{ImageID: "ami-2", ImageLocation: "amazon/amazon-eks-node-1.28-v20240202", Release: "v20240202", PublishDate: publishDate},
+				{ImageID: "ami-1", ImageLocation: "amazon/bottlerocket-aws-k8s-1.28-x86_64-v1.19.0-c4d76236", Release: "v1.19.0-c4d76236", ReleaseVersion: "1.19.0-c4d76236", PublishDate: publishDate},
+			},
+			expectedSsmCalls: 1,
+			expectedEc2Calls: 1,
+		},
+		{name: "keys from other regions are not prefetched",
+			keys: []AmiKey{
+				{Region: "eu-west-1", AmiType: "AL2_x86_64", Version: "1.28"},
+				{Region: "us-west-2", AmiType: "SOMETHINGNEW_x86_64", Version: "1.28"},
+			},
+			expectedValue: []AmiRecord{
+				{ImageID: "ami-2", ImageLocation: "amazon/amazon-eks-node-1.28-v20240202", Release: "v20240202", PublishDate: publishDate},
+				{},
+			},
+			expectedSsmCalls: 1,
+			expectedEc2Calls: 1,
+		},
+		{name: "parameters are fetched by ten and misses are returned without another lookup",
+			keys:          missingKeys(11),
+			expectedValue: make([]AmiRecord, 11),
+			expectedErrors: []string{"ParameterNotFound", "ParameterNotFound", "ParameterNotFound", "ParameterNotFound", "ParameterNotFound", "ParameterNotFound",
+				"ParameterNotFound", "ParameterNotFound", "ParameterNotFound", "ParameterNotFound", "ParameterNotFound"},
+			expectedSsmCalls: 2,
+			expectedEc2Calls: 0,
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		var ssmCalls, ec2Calls atomic.Int32
+		clients := TestClients{
+			AwsSsm: TestSsm{OutputGetParameters: mockedParameters, Calls: &ssmCalls},
+			AwsEc2: TestEc2{OutputImages: &mockedImages, Calls: &ec2Calls},
+		}
+		resolver := NewAmiResolver(nil)
+
+		err := resolver.Prefetch("eu-west-1", test.keys, clients, context.Background())
+		assert.NoError(t, err)
+
+		var output []AmiRecord
+		var errorCodes []string
+		for _, key := range test.keys {
+			if key.Region != "eu-west-1" {
+				output = append(output, AmiRecord{})
+
+				continue
+			}
+			record, err := resolver.Resolve(key, clients, context.Background())
+			if err != nil {
+				errorCodes = append(errorCodes, ErrorCode(err))
+			}
+			output = append(output, record)
+		}
+
+		assert.Equal(t, test.expectedValue, output)
+		assert.Equal(t, test.expectedErrors, errorCodes)
+		assert.Equal(t, test.expectedSsmCalls, ssmCalls.Load())
+		assert.Equal(t, test.expectedEc2Calls, ec2Calls.Load())
+	}
+}
+
+func missingKeys(count int) []AmiKey {
+	keys := make([]AmiKey, 0, count)
+	for i := range count {
+		keys = append(keys, AmiKey{Region: "eu-west-1", AmiType: "AL2_x86_64", Version: fmt.Sprintf("1.%d", 40+i)})
+	}
+
+	return keys
+}
@@ -3,7 +3,7 @@ package aws
 import (
 	"context"
 
-	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
 	"github.com/rs/zerolog/log"
 )
 
@@ -13,23 +13,15 @@ func GetClusters(region string, awsEks EKS, ctx context.Context) ([]string, erro
 
 	logWithContext.Debug().Str("region", region).Msg("looking for clusters")
 
-	input := &eks.ListClustersInput{}
-
-	for {
-		output, err := awsEks.ListClusters(input)
+	paginator := eks.NewListClustersPaginator(awsEks, &eks.ListClustersInput{})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
 		if err != nil {
 			return nil, err
 		}
-		for _, v := range output.Clusters {
-			clusters = append(clusters, *v)
-		}
+		clusters = append(clusters, output.Clusters...)
 		if output.NextToken != nil {
 			logWithContext.Debug().Str("region", region).Strs("clusters", clusters).Str("token", *output.NextToken).Msg("ListClusters request exceed maxResults")
-			input = &eks.ListClustersInput{
-				NextToken: output.NextToken,
-			}
-		} else {
-			break
 		}
 	}
 
@@ -5,8 +5,8 @@ import (
 	"fmt"
 	"testing"
 
-	awsLib "github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/eks"
+	awsLib "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -22,9 +22,9 @@ func TestGetClusters(t *testing.T) {
 	}{
 		{name: "full listing at once (without nextToken)",
 			mockedOutput: eks.ListClustersOutput{
-				Clusters: []*string{
-					awsLib.String("cluster-1"),
-					awsLib.String("cluster-2"),
+				Clusters: []string{
+					"cluster-1",
+					"cluster-2",
 				},
 				NextToken: nil,
 			},
@@ -34,9 +34,9 @@ func TestGetClusters(t *testing.T) {
 		},
 		{name: "exceed maxResults (nextToken is set)",
 			mockedOutput: eks.ListClustersOutput{
-				Clusters: []*string{
-					awsLib.String("cluster-1"),
-					awsLib.String("cluster-2"),
+				Clusters: []string{
+					"cluster-1",
+					"cluster-2",
 				},
 				NextToken: awsLib.String("111"),
 			},
@@ -48,7 +48,7 @@ func TestGetClusters(t *testing.T) {
 
 	for _, test := range tests {
 		fmt.Printf("test: %s\n", test.name)
-		awsEks := testEks{OutputListClusters: &test.mockedOutput}
+		awsEks := TestEks{OutputListClusters: &test.mockedOutput}
 
 		output, err := GetClusters(test.region, awsEks, context.Background())
 
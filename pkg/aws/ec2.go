@@ -1,22 +1,32 @@
 package aws
 
 import (
+	"context"
 	"fmt"
 
-	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
 )
 
 type Ec2 interface {
-	DescribeRegions(input *ec2.DescribeRegionsInput) (*ec2.DescribeRegionsOutput, error)
-	DescribeImages(input *ec2.DescribeImagesInput) (*ec2.DescribeImagesOutput, error)
+	DescribeRegions(ctx context.Context, input *ec2.DescribeRegionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRegionsOutput, error)
+	DescribeImages(ctx context.Context, input *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error)
 }
 
 type RealEc2 struct {
-	Svc *ec2.EC2
+	Svc       *ec2.Client
+	Account   string
+	Region    string
+	Throttler *Throttler
 }
 
-func (t RealEc2) DescribeRegions(input *ec2.DescribeRegionsInput) (*ec2.DescribeRegionsOutput, error) {
-	result, err := t.Svc.DescribeRegions(input)
+func (t RealEc2) DescribeRegions(ctx context.Context, input *ec2.DescribeRegionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRegionsOutput, error) {
+	var result *ec2.DescribeRegionsOutput
+	err := t.Throttler.Call(ctx, "ec2", t.Account, t.Region, func() error {
+		var err error
+		result, err = t.Svc.DescribeRegions(ctx, input, optFns...)
+
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("error describing regions: %w", err)
 	}
@@ -24,8 +34,14 @@ func (t RealEc2) DescribeRegions(input *ec2.DescribeRegionsInput) (*ec2.Describe
 	return result, nil
 }
 
-func (t RealEc2) DescribeImages(input *ec2.DescribeImagesInput) (*ec2.DescribeImagesOutput, error) {
-	result, err := t.Svc.DescribeImages(input)
+func (t RealEc2) DescribeImages(ctx context.Context, input *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error) {
+	var result *ec2.DescribeImagesOutput
+	err := t.Throttler.Call(ctx, "ec2", t.Account, t.Region, func() error {
+		var err error
+		result, err = t.Svc.DescribeImages(ctx, input, optFns...)
+
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("error describing images: %w", err)
 	}
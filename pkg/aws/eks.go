@@ -1,23 +1,36 @@
 package aws
 
 import (
+	"context"
 	"fmt"
 
-	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
 )
 
 type EKS interface {
-	ListClusters(input *eks.ListClustersInput) (*eks.ListClustersOutput, error)
-	ListNodegroups(input *eks.ListNodegroupsInput) (*eks.ListNodegroupsOutput, error)
-	DescribeNodegroup(input *eks.DescribeNodegroupInput) (*eks.DescribeNodegroupOutput, error)
+	ListClusters(ctx context.Context, input *eks.ListClustersInput, optFns ...func(*eks.Options)) (*eks.ListClustersOutput, error)
+	ListNodegroups(ctx context.Context, input *eks.ListNodegroupsInput, optFns ...func(*eks.Options)) (*eks.ListNodegroupsOutput, error)
+	DescribeNodegroup(ctx context.Context, input *eks.DescribeNodegroupInput, optFns ...func(*eks.Options)) (*eks.DescribeNodegroupOutput, error)
+	UpdateNodegroupVersion(ctx context.Context, input *eks.UpdateNodegroupVersionInput, optFns ...func(*eks.Options)) (*eks.UpdateNodegroupVersionOutput, error)
+	ListUpdates(ctx context.Context, input *eks.ListUpdatesInput, optFns ...func(*eks.Options)) (*eks.ListUpdatesOutput, error)
+	DescribeUpdate(ctx context.Context, input *eks.DescribeUpdateInput, optFns ...func(*eks.Options)) (*eks.DescribeUpdateOutput, error)
 }
 
 type RealEks struct {
-	Svc *eks.EKS
+	Svc       *eks.Client
+	Account   string
+	Region    string
+	Throttler *Throttler
 }
 
-func (t RealEks) ListClusters(input *eks.ListClustersInput) (*eks.ListClustersOutput, error) {
-	result, err := t.Svc.ListClusters(input)
+func (t RealEks) ListClusters(ctx context.Context, input *eks.ListClustersInput, optFns ...func(*eks.Options)) (*eks.ListClustersOutput, error) {
+	var result *eks.ListClustersOutput
+	err := t.Throttler.Call(ctx, "eks", t.Account, t.Region, func() error {
+		var err error
+		result, err = t.Svc.ListClusters(ctx, input, optFns...)
+
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("error listing clusters: %w", err)
 	}
@@ -25,8 +38,14 @@ func (t RealEks) ListClusters(input *eks.ListClustersInput) (*eks.ListClustersOu
 	return result, nil
 }
 
-func (t RealEks) ListNodegroups(input *eks.ListNodegroupsInput) (*eks.ListNodegroupsOutput, error) {
-	result, err := t.Svc.ListNodegroups(input)
+func (t RealEks) ListNodegroups(ctx context.Context, input *eks.ListNodegroupsInput, optFns ...func(*eks.Options)) (*eks.ListNodegroupsOutput, error) {
+	var result *eks.ListNodegroupsOutput
+	err := t.Throttler.Call(ctx, "eks", t.Account, t.Region, func() error {
+		var err error
+		result, err = t.Svc.ListNodegroups(ctx, input, optFns...)
+
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("error listing nodegroups: %w", err)
 	}
@@ -34,11 +53,62 @@ func (t RealEks) ListNodegroups(input *eks.ListNodegroupsInput) (*eks.ListNodegr
 	return result, nil
 }
 
-func (t RealEks) DescribeNodegroup(input *eks.DescribeNodegroupInput) (*eks.DescribeNodegroupOutput, error) {
-	result, err := t.Svc.DescribeNodegroup(input)
+func (t RealEks) DescribeNodegroup(ctx context.Context, input *eks.DescribeNodegroupInput, optFns ...func(*eks.Options)) (*eks.DescribeNodegroupOutput, error) {
+	var result *eks.DescribeNodegroupOutput
+	err := t.Throttler.Call(ctx, "eks", t.Account, t.Region, func() error {
+		var err error
+		result, err = t.Svc.DescribeNodegroup(ctx, input, optFns...)
+
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("error describing nodegroup: %w", err)
 	}
 
 	return result, nil
 }
+
+func (t RealEks) UpdateNodegroupVersion(ctx context.Context, input *eks.UpdateNodegroupVersionInput, optFns ...func(*eks.Options)) (*eks.UpdateNodegroupVersionOutput, error) {
+	var result *eks.UpdateNodegroupVersionOutput
+	err := t.Throttler.CallNonIdempotent(ctx, "eks", t.Account, t.Region, func() error {
+		var err error
+		result, err = t.Svc.UpdateNodegroupVersion(ctx, input, optFns...)
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error updating nodegroup version: %w", err)
+	}
+
+	return result, nil
+}
+
+func (t RealEks) ListUpdates(ctx context.Context, input *eks.ListUpdatesInput, optFns ...func(*eks.Options)) (*eks.ListUpdatesOutput, error) {
+	var result *eks.ListUpdatesOutput
+	err := t.Throttler.Call(ctx, "eks", t.Account, t.Region, func() error {
+		var err error
+		result, err = t.Svc.ListUpdates(ctx, input, optFns...)
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing updates: %w", err)
+	}
+
+	return result, nil
+}
+
+func (t RealEks) DescribeUpdate(ctx context.Context, input *eks.DescribeUpdateInput, optFns ...func(*eks.Options)) (*eks.DescribeUpdateOutput, error) {
+	var result *eks.DescribeUpdateOutput
+	err := t.Throttler.Call(ctx, "eks", t.Account, t.Region, func() error {
+		var err error
+		result, err = t.Svc.DescribeUpdate(ctx, input, optFns...)
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error describing update: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,79 @@
+package aws
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	awsLib "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+var (
+	ErrEndpointConflict = errors.New("fips and dual-stack endpoints can not be combined with custom endpoint urls")
+	ErrRegionalEndpoint = errors.New("eks, ssm and ec2 endpoint urls are used for the clients of every region")
+)
+
+// placeholder of regional endpoint urls which is replaced by the region of the client
+const regionPlaceholder = "{{.Region}}"
+
+type EndpointOptions struct {
+	Eks           string
+	Ssm           string
+	Ec2           string
+	Sts           string
+	Organizations string
+	UseFIPS       bool
+	UseDualStack  bool
+	CABundle      string
+	// regions set with --regions, a regional endpoint url without the placeholder is only allowed for a single one
+	Regions []string
+}
+
+func (o EndpointOptions) hasCustomEndpoints() bool {
+	return o.Eks != "" || o.Ssm != "" || o.Ec2 != "" || o.Sts != "" || o.Organizations != ""
+}
+
+func (o EndpointOptions) loadOptions() ([]func(*config.LoadOptions) error, error) {
+	var loadOptions []func(*config.LoadOptions) error
+
+	// settings which are not set here are still taken from the environment by the sdk
+	// (eg. AWS_ENDPOINT_URL_EKS, AWS_USE_FIPS_ENDPOINT, AWS_CA_BUNDLE)
+	if (o.UseFIPS || o.UseDualStack) && o.hasCustomEndpoints() {
+		return nil, ErrEndpointConflict
+	}
+	// a regional endpoint url without the placeholder would get the calls of every region
+	for _, url := range []string{o.Eks, o.Ssm, o.Ec2} {
+		if url != "" && !strings.Contains(url, regionPlaceholder) && len(o.Regions) != 1 {
+			return nil, fmt.Errorf("endpoint url (%s) has to contain %s unless exactly one region is set with --regions: %w", url, regionPlaceholder, ErrRegionalEndpoint)
+		}
+	}
+	if o.UseFIPS {
+		loadOptions = append(loadOptions, config.WithUseFIPSEndpoint(awsLib.FIPSEndpointStateEnabled))
+	}
+	if o.UseDualStack {
+		loadOptions = append(loadOptions, config.WithUseDualStackEndpoint(awsLib.DualStackEndpointStateEnabled))
+	}
+	if o.CABundle != "" {
+		caBundle, err := os.ReadFile(o.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("error reading ca bundle: %w", err)
+		}
+		loadOptions = append(loadOptions, config.WithCustomCABundle(bytes.NewReader(caBundle)))
+	}
+
+	return loadOptions, nil
+}
+
+func setRegionalBaseEndpoint(baseEndpoint **string, url, region string) {
+	setBaseEndpoint(baseEndpoint, strings.ReplaceAll(url, regionPlaceholder, region))
+}
+
+func setBaseEndpoint(baseEndpoint **string, url string) {
+	// an empty url keeps the endpoint configured in the environment
+	if url != "" {
+		*baseEndpoint = awsLib.String(url)
+	}
+}
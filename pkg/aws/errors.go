@@ -0,0 +1,49 @@
+package aws
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/smithy-go"
+	"github.com/loomhq/eks-ng-ami-updater/pkg/utils"
+)
+
+// Credential errors like UnrecognizedClientException fail every region, so they are findings and not listed here.
+var regionUnavailableCodes = []string{
+	"AccessDenied",
+	"AccessDeniedException",
+	"OptInRequired",
+}
+
+type ClusterError struct {
+	Region  string
+	Cluster string
+	Err     error
+}
+
+func (e *ClusterError) Error() string {
+	return fmt.Sprintf("region: %s, cluster: %s : %v", e.Region, e.Cluster, e.Err)
+}
+
+func (e *ClusterError) Unwrap() error {
+	return e.Err
+}
+
+func ErrorCode(err error) string {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.ErrorCode()
+	}
+
+	return ""
+}
+
+func IsRegionUnavailable(err error) bool {
+	// a cluster which can not be read does not make the whole region unavailable
+	var clusterError *ClusterError
+	if errors.As(err, &clusterError) {
+		return false
+	}
+
+	return utils.Contains(regionUnavailableCodes, ErrorCode(err))
+}
@@ -0,0 +1,91 @@
+package aws
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorCode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		err           error
+		expectedValue string
+	}{
+		{name: "aws error",
+			err:           &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized"},
+			expectedValue: "AccessDeniedException",
+		},
+		{name: "wrapped aws error",
+			err:           fmt.Errorf("error describing nodegroup: %w", &smithy.GenericAPIError{Code: "ThrottlingException", Message: "rate exceeded"}),
+			expectedValue: "ThrottlingException",
+		},
+		{name: "not aws error",
+			err:           fmt.Errorf("nodegroup's ami type (SOMETHINGNEW_x86_64) is not recognize"),
+			expectedValue: "",
+		},
+		{name: "nil error",
+			err:           nil,
+			expectedValue: "",
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+
+		output := ErrorCode(test.err)
+
+		assert.Equal(t, test.expectedValue, output)
+	}
+}
+
+func TestIsRegionUnavailable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		err           error
+		expectedValue bool
+	}{
+		{name: "eks access is denied by scp",
+			err:           fmt.Errorf("error listing clusters: %w", &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "explicit deny in a service control policy"}),
+			expectedValue: true,
+		},
+		{name: "region is not enabled for the account",
+			err:           fmt.Errorf("error listing clusters: %w", &smithy.GenericAPIError{Code: "OptInRequired", Message: "the account is not subscribed to the region"}),
+			expectedValue: true,
+		},
+		{name: "invalid credentials are not an unavailable region",
+			err:           fmt.Errorf("error listing clusters: %w", &smithy.GenericAPIError{Code: "UnrecognizedClientException", Message: "the security token included in the request is invalid"}),
+			expectedValue: false,
+		},
+		{name: "expired credentials are not an unavailable region",
+			err:           fmt.Errorf("error describing regions: %w", &smithy.GenericAPIError{Code: "AuthFailure", Message: "aws was not able to validate the provided access credentials"}),
+			expectedValue: false,
+		},
+		{name: "throttling is not an unavailable region",
+			err:           fmt.Errorf("error listing clusters: %w", &smithy.GenericAPIError{Code: "ThrottlingException", Message: "rate exceeded"}),
+			expectedValue: false,
+		},
+		{name: "denied cluster call is not an unavailable region",
+			err:           &ClusterError{Region: "eu-west-1", Cluster: "cluster-1", Err: &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized to perform eks:ListNodegroups"}},
+			expectedValue: false,
+		},
+		{name: "not aws error",
+			err:           fmt.Errorf("some error"),
+			expectedValue: false,
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+
+		output := IsRegionUnavailable(test.err)
+
+		assert.Equal(t, test.expectedValue, output)
+	}
+}
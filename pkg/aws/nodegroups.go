@@ -3,11 +3,13 @@ package aws
 import (
 	"context"
 
-	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	"github.com/loomhq/eks-ng-ami-updater/pkg/utils"
 	"github.com/rs/zerolog/log"
 )
 
 type NodeGroup struct {
+	AccountID     string
 	Region        string
 	ClusterName   string
 	NodegroupName string
@@ -18,26 +20,17 @@ func GetNodegroupsFromCluster(clusterName, region string, awsEks EKS, ctx contex
 	logWithContext := log.Ctx(ctx).With().Str("function", "GetNodegroupsFromCluster").Logger()
 
 	logWithContext.Debug().Str("region", region).Str("cluster", clusterName).Msg("looking for nodegroups")
-	input := &eks.ListNodegroupsInput{
+	paginator := eks.NewListNodegroupsPaginator(awsEks, &eks.ListNodegroupsInput{
 		ClusterName: &clusterName,
-	}
-
-	for {
-		output, err := awsEks.ListNodegroups(input)
+	})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
 		if err != nil {
 			return nil, err
 		}
-		for _, v := range output.Nodegroups {
-			nodegroups = append(nodegroups, *v)
-		}
-
+		nodegroups = append(nodegroups, output.Nodegroups...)
 		if output.NextToken != nil {
 			logWithContext.Debug().Str("region", region).Str("cluster", clusterName).Str("token", *output.NextToken).Msg("ListNodegroups request exceed maxResults")
-			input = &eks.ListNodegroupsInput{
-				NextToken: output.NextToken,
-			}
-		} else {
-			break
 		}
 	}
 
@@ -46,39 +39,45 @@ func GetNodegroupsFromCluster(clusterName, region string, awsEks EKS, ctx contex
 	return nodegroups, nil
 }
 
-func GetNodegroupsFromRegion(region string, ctx context.Context) ([]NodeGroup, error) {
+func GetNodegroupsFromRegion(region string, awsEks EKS, limiter *utils.Limiter, failFast bool, ctx context.Context) ([]NodeGroup, []*ClusterError, error) {
 	var nodeGroupFromCluster []NodeGroup
+	var clusterErrors []*ClusterError
 	logWithContext := log.Ctx(ctx).With().Str("function", "GetNodegroupsFromRegion").Logger()
 
-	svcEks, err := EksClientSetup(region)
+	// the limiter is shared with the other regions, so it bounds the api calls and not the regions
+	clusters, err := utils.Limit(limiter, func() ([]string, error) { return GetClusters(region, awsEks, ctx) }, ctx)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	awsEks := RealEks{Svc: svcEks}
 
-	clusters, err := GetClusters(region, awsEks, ctx)
-	if err != nil {
-		return nil, err
-	}
+	nodegroupsFromClusters, errs := utils.MapContext(clusters, limiter, failFast, func(cluster string, ctx context.Context) ([]string, error) {
+		return GetNodegroupsFromCluster(cluster, region, awsEks, ctx)
+	}, ctx)
 
-	for _, cluster := range clusters {
-		nodegroups, err := GetNodegroupsFromCluster(cluster, region, awsEks, ctx)
-		if err != nil {
-			return nil, err
+	for i, cluster := range clusters {
+		if errs[i] != nil {
+			logWithContext.Debug().Str("region", region).Str("cluster", cluster).Err(errs[i]).Msg("unable to list nodegroups for cluster")
+			clusterError := &ClusterError{Region: region, Cluster: cluster, Err: errs[i]}
+			if failFast {
+				return nil, nil, clusterError
+			}
+			clusterErrors = append(clusterErrors, clusterError)
+
+			continue
 		}
-		for _, nodegroup := range nodegroups {
+		for _, nodegroup := range nodegroupsFromClusters[i] {
 			nodeGroupFromCluster = append(nodeGroupFromCluster, NodeGroup{Region: region, ClusterName: cluster, NodegroupName: nodegroup})
 			logWithContext.Debug().Str("region", region).Str("cluster", cluster).Str("nodegroup", nodegroup).Msg("add nodegroup to the ami upgrade nodegroups checking list")
 		}
 	}
 
-	return nodeGroupFromCluster, nil
+	return nodeGroupFromCluster, clusterErrors, nil
 }
 
 func GetNodegroupDescription(nodegroup NodeGroup, awsEks EKS, ctx context.Context) (eks.DescribeNodegroupOutput, error) {
 	logWithContext := log.Ctx(ctx).With().Str("function", "GetNodegroupDescription").Logger()
 
-	output, err := awsEks.DescribeNodegroup(&eks.DescribeNodegroupInput{
+	output, err := awsEks.DescribeNodegroup(ctx, &eks.DescribeNodegroupInput{
 		ClusterName:   &nodegroup.ClusterName,
 		NodegroupName: &nodegroup.NodegroupName,
 	})
@@ -86,21 +85,21 @@ func GetNodegroupDescription(nodegroup NodeGroup, awsEks EKS, ctx context.Contex
 		return eks.DescribeNodegroupOutput{}, err
 	}
 
-	logWithContext.Debug().Str("AmiType", *output.Nodegroup.AmiType).Str("AmiVersion", *output.Nodegroup.Version).
+	logWithContext.Debug().Str("AmiType", string(output.Nodegroup.AmiType)).Str("AmiVersion", *output.Nodegroup.Version).
 		Str("region", nodegroup.Region).Str("cluster", nodegroup.ClusterName).Str("nodegroup", nodegroup.NodegroupName).Msg("actual nodegroup AMI Id")
 
 	for k, v := range output.Nodegroup.Tags {
-		logWithContext.Debug().Str("tagKey", k).Str("tagValue", *v).Msgf("Tags from %s nodegroup", *output.Nodegroup.NodegroupName)
+		logWithContext.Debug().Str("tagKey", k).Str("tagValue", v).Msgf("Tags from %s nodegroup", *output.Nodegroup.NodegroupName)
 	}
 
 	return *output, nil
 }
 
-func HasNodegroupTag(tag string, nodegroupTags map[string]*string, ctx context.Context) (bool, error) {
+func HasNodegroupTag(tag string, nodegroupTags map[string]string, ctx context.Context) (bool, error) {
 	logWithContext := log.Ctx(ctx).With().Str("function", "HasNodegroupTag").Logger()
 
 	for k, v := range nodegroupTags {
-		tagTemp := k + ":" + *v
+		tagTemp := k + ":" + v
 		if tag == tagTemp {
 			logWithContext.Debug().Str("tagVar", tag).Str("nodegroupTag", tagTemp).Msg("Flag tag is found for nodegroup")
 
@@ -4,9 +4,12 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
-	awsLib "github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/eks"
+	awsLib "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	eksTypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/loomhq/eks-ng-ami-updater/pkg/utils"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -23,9 +26,9 @@ func TestGetNodegroupsFromCluster(t *testing.T) {
 	}{
 		{name: "full listing at once (without nextToken)",
 			mockedOutput: eks.ListNodegroupsOutput{
-				Nodegroups: []*string{
-					awsLib.String("nodegroup-1"),
-					awsLib.String("nodegroup-2"),
+				Nodegroups: []string{
+					"nodegroup-1",
+					"nodegroup-2",
 				},
 			},
 			clusterName:   "cluster-1",
@@ -36,9 +39,9 @@ func TestGetNodegroupsFromCluster(t *testing.T) {
 		{name: "exceed maxResults (nextToken is set)",
 			mockedOutput: eks.ListNodegroupsOutput{
 				NextToken: awsLib.String("111"),
-				Nodegroups: []*string{
-					awsLib.String("nodegroup-1"),
-					awsLib.String("nodegroup-2"),
+				Nodegroups: []string{
+					"nodegroup-1",
+					"nodegroup-2",
 				},
 			},
 			clusterName:   "cluster-1",
@@ -50,7 +53,7 @@ func TestGetNodegroupsFromCluster(t *testing.T) {
 
 	for _, test := range tests {
 		fmt.Printf("test: %s\n", test.name)
-		awsEks := testEks{OutputListNodegroups: &test.mockedOutput}
+		awsEks := TestEks{OutputListNodegroups: &test.mockedOutput}
 
 		output, err := GetNodegroupsFromCluster(test.clusterName, test.region, awsEks, context.Background())
 
@@ -65,72 +68,72 @@ func TestGetNodegroupTags(t *testing.T) {
 	tests := []struct {
 		name          string
 		tag           string
-		nodegroupTags map[string]*string
+		nodegroupTags map[string]string
 		expectedValue bool
 		expectedError error
 	}{
 		{name: "tag is found in nodegroup Tags",
 			tag: "env:staging",
-			nodegroupTags: map[string]*string{
-				"tag1": awsLib.String("value1"),
-				"env":  awsLib.String("staging"),
-				"tag3": awsLib.String("value3"),
+			nodegroupTags: map[string]string{
+				"tag1": "value1",
+				"env":  "staging",
+				"tag3": "value3",
 			},
 			expectedValue: true,
 			expectedError: nil,
 		},
 		{name: "tag is not found in nodegroup Tags",
 			tag: "env:production",
-			nodegroupTags: map[string]*string{
-				"tag1": awsLib.String("value1"),
-				"env":  awsLib.String("staging"),
-				"tag3": awsLib.String("value3"),
+			nodegroupTags: map[string]string{
+				"tag1": "value1",
+				"env":  "staging",
+				"tag3": "value3",
 			},
 			expectedValue: false,
 			expectedError: nil,
 		},
 		{name: "nodegroup tags list is empty",
 			tag:           "env:staging",
-			nodegroupTags: map[string]*string{},
+			nodegroupTags: map[string]string{},
 			expectedValue: false,
 			expectedError: nil,
 		},
 		{name: "only key is provided for tag #1",
 			tag: "env",
-			nodegroupTags: map[string]*string{
-				"tag1": awsLib.String("value1"),
-				"env":  awsLib.String("staging"),
-				"tag3": awsLib.String("value3"),
+			nodegroupTags: map[string]string{
+				"tag1": "value1",
+				"env":  "staging",
+				"tag3": "value3",
 			},
 			expectedValue: false,
 			expectedError: nil,
 		},
 		{name: "only key is provided for tag #2",
 			tag: "env:",
-			nodegroupTags: map[string]*string{
-				"tag1": awsLib.String("value1"),
-				"env":  awsLib.String(""),
-				"tag3": awsLib.String("value3"),
+			nodegroupTags: map[string]string{
+				"tag1": "value1",
+				"env":  "",
+				"tag3": "value3",
 			},
 			expectedValue: true,
 			expectedError: nil,
 		},
 		{name: "only value is provided for tag #1",
 			tag: ":staging",
-			nodegroupTags: map[string]*string{
-				"tag1": awsLib.String("value1"),
-				"env":  awsLib.String("staging"),
-				"tag3": awsLib.String("value3"),
+			nodegroupTags: map[string]string{
+				"tag1": "value1",
+				"env":  "staging",
+				"tag3": "value3",
 			},
 			expectedValue: false,
 			expectedError: nil,
 		},
 		{name: "only value is provided for tag #2",
 			tag: ":staging",
-			nodegroupTags: map[string]*string{
-				"tag1": awsLib.String("value1"),
-				"":     awsLib.String("staging"),
-				"tag3": awsLib.String("value3"),
+			nodegroupTags: map[string]string{
+				"tag1": "value1",
+				"":     "staging",
+				"tag3": "value3",
 			},
 			expectedValue: true,
 			expectedError: nil,
@@ -146,3 +149,50 @@ func TestGetNodegroupTags(t *testing.T) {
 		assert.Equal(t, test.expectedError, err)
 	}
 }
+
+func BenchmarkGetNodegroupsFromRegion(b *testing.B) {
+	var clusters []string
+	for i := range 20 {
+		clusters = append(clusters, fmt.Sprintf("cluster-%d", i))
+	}
+
+	for _, concurrency := range []int{1, 5, 20} {
+		b.Run(fmt.Sprintf("concurrency-%d", concurrency), func(b *testing.B) {
+			awsEks := TestEks{
+				OutputListClusters:   &eks.ListClustersOutput{Clusters: clusters},
+				OutputListNodegroups: &eks.ListNodegroupsOutput{Nodegroups: []string{"nodegroup-1", "nodegroup-2"}},
+				Latency:              time.Millisecond,
+			}
+
+			for b.Loop() {
+				_, _, _ = GetNodegroupsFromRegion("us-west-1", awsEks, utils.NewLimiter(concurrency), false, context.Background())
+			}
+		})
+	}
+}
+
+func BenchmarkGetNodegroupDescription(b *testing.B) {
+	var nodegroups []NodeGroup
+	for i := range 100 {
+		nodegroups = append(nodegroups, NodeGroup{Region: "us-west-1", ClusterName: "cluster-1", NodegroupName: fmt.Sprintf("nodegroup-%d", i)})
+	}
+
+	for _, concurrency := range []int{1, 10, 50} {
+		b.Run(fmt.Sprintf("concurrency-%d", concurrency), func(b *testing.B) {
+			awsEks := TestEks{
+				OutputDescribeNodegroup: &eks.DescribeNodegroupOutput{Nodegroup: &eksTypes.Nodegroup{
+					NodegroupName: awsLib.String("nodegroup"),
+					AmiType:       eksTypes.AMITypes("BOTTLEROCKET_x86_64"),
+					Version:       awsLib.String("1.28"),
+				}},
+				Latency: time.Millisecond,
+			}
+
+			for b.Loop() {
+				_, _ = utils.Map(nodegroups, concurrency, func(nodegroup NodeGroup) (eks.DescribeNodegroupOutput, error) {
+					return GetNodegroupDescription(nodegroup, awsEks, context.Background())
+				})
+			}
+		})
+	}
+}
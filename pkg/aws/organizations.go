@@ -0,0 +1,82 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+)
+
+type Organizations interface {
+	ListAccounts(ctx context.Context, input *organizations.ListAccountsInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsOutput, error)
+	ListAccountsForParent(ctx context.Context, input *organizations.ListAccountsForParentInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsForParentOutput, error)
+	ListOrganizationalUnitsForParent(ctx context.Context, input *organizations.ListOrganizationalUnitsForParentInput, optFns ...func(*organizations.Options)) (*organizations.ListOrganizationalUnitsForParentOutput, error)
+	ListTagsForResource(ctx context.Context, input *organizations.ListTagsForResourceInput, optFns ...func(*organizations.Options)) (*organizations.ListTagsForResourceOutput, error)
+}
+
+type RealOrganizations struct {
+	Svc       *organizations.Client
+	Account   string
+	Region    string
+	Throttler *Throttler
+}
+
+func (t RealOrganizations) ListAccounts(ctx context.Context, input *organizations.ListAccountsInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsOutput, error) {
+	var result *organizations.ListAccountsOutput
+	err := t.Throttler.Call(ctx, "organizations", t.Account, t.Region, func() error {
+		var err error
+		result, err = t.Svc.ListAccounts(ctx, input, optFns...)
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing accounts: %w", err)
+	}
+
+	return result, nil
+}
+
+func (t RealOrganizations) ListAccountsForParent(ctx context.Context, input *organizations.ListAccountsForParentInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsForParentOutput, error) {
+	var result *organizations.ListAccountsForParentOutput
+	err := t.Throttler.Call(ctx, "organizations", t.Account, t.Region, func() error {
+		var err error
+		result, err = t.Svc.ListAccountsForParent(ctx, input, optFns...)
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing accounts for parent: %w", err)
+	}
+
+	return result, nil
+}
+
+func (t RealOrganizations) ListOrganizationalUnitsForParent(ctx context.Context, input *organizations.ListOrganizationalUnitsForParentInput, optFns ...func(*organizations.Options)) (*organizations.ListOrganizationalUnitsForParentOutput, error) {
+	var result *organizations.ListOrganizationalUnitsForParentOutput
+	err := t.Throttler.Call(ctx, "organizations", t.Account, t.Region, func() error {
+		var err error
+		result, err = t.Svc.ListOrganizationalUnitsForParent(ctx, input, optFns...)
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing organizational units for parent: %w", err)
+	}
+
+	return result, nil
+}
+
+func (t RealOrganizations) ListTagsForResource(ctx context.Context, input *organizations.ListTagsForResourceInput, optFns ...func(*organizations.Options)) (*organizations.ListTagsForResourceOutput, error) {
+	var result *organizations.ListTagsForResourceOutput
+	err := t.Throttler.Call(ctx, "organizations", t.Account, t.Region, func() error {
+		var err error
+		result, err = t.Svc.ListTagsForResource(ctx, input, optFns...)
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing tags for resource: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,98 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	awsLib "github.com/aws/aws-sdk-go-v2/aws"
+	organizationsTypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetOrganizationAccounts(t *testing.T) {
+	t.Parallel()
+
+	awsOrganizations := TestOrganizations{
+		Accounts: []organizationsTypes.Account{
+			{Id: awsLib.String("444444444444"), State: organizationsTypes.AccountStateActive},
+			{Id: awsLib.String("111111111111"), State: organizationsTypes.AccountStateActive},
+			{Id: awsLib.String("222222222222"), State: organizationsTypes.AccountStateActive},
+			{Id: awsLib.String("333333333333"), State: organizationsTypes.AccountStateSuspended},
+		},
+		ParentIDs: map[string]string{
+			"ou-ab12-11111111": "r-ab12",
+			"ou-ab12-22222222": "ou-ab12-11111111",
+			"111111111111":     "r-ab12",
+			"222222222222":     "ou-ab12-11111111",
+			"333333333333":     "ou-ab12-11111111",
+			"444444444444":     "ou-ab12-22222222",
+		},
+		Tags: map[string][]organizationsTypes.Tag{
+			"111111111111": {{Key: awsLib.String("env"), Value: awsLib.String("production")}, {Key: awsLib.String("owner:team"), Value: awsLib.String("platform")}},
+			"222222222222": {{Key: awsLib.String("owner"), Value: awsLib.String("team:platform")}},
+			"444444444444": {{Key: awsLib.String("env"), Value: awsLib.String("staging")}, {Key: awsLib.String("eks"), Value: awsLib.String("true")}},
+		},
+	}
+
+	tests := []struct {
+		name                string
+		organizationalUnits []string
+		tag                 string
+		awsOrganizations    TestOrganizations
+		expectedValue       []string
+		expectedError       error
+	}{
+		{name: "all active accounts",
+			awsOrganizations: awsOrganizations,
+			expectedValue:    []string{"111111111111", "222222222222", "444444444444"},
+		},
+		{name: "accounts from organizational unit and its nested units",
+			organizationalUnits: []string{"ou-ab12-11111111"},
+			awsOrganizations:    awsOrganizations,
+			expectedValue:       []string{"222222222222", "444444444444"},
+		},
+		{name: "accounts from overlapping organizational units",
+			organizationalUnits: []string{"ou-ab12-22222222", "ou-ab12-11111111"},
+			awsOrganizations:    awsOrganizations,
+			expectedValue:       []string{"222222222222", "444444444444"},
+		},
+		{name: "accounts within tag",
+			tag:              "env:staging",
+			awsOrganizations: awsOrganizations,
+			expectedValue:    []string{"444444444444"},
+		},
+		{name: "no account within tag",
+			tag:              "env:development",
+			awsOrganizations: awsOrganizations,
+			expectedValue:    nil,
+		},
+		{name: "tag key and value are compared separately",
+			tag:              "owner:team:platform",
+			awsOrganizations: awsOrganizations,
+			expectedValue:    []string{"222222222222"},
+		},
+		{name: "tag without value is rejected",
+			tag:              "env",
+			awsOrganizations: awsOrganizations,
+			expectedValue:    nil,
+			expectedError:    errors.New("organization tag (env) has to be in the key:value format"),
+		},
+		{name: "accounts cannot be listed",
+			awsOrganizations: TestOrganizations{ErrListAccounts: &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized"}},
+			expectedValue:    nil,
+			expectedError:    &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized"},
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+
+		output, err := GetOrganizationAccounts(test.organizationalUnits, test.tag, test.awsOrganizations, context.Background())
+
+		assert.Equal(t, test.expectedValue, output)
+		assert.Equal(t, test.expectedError, err)
+	}
+}
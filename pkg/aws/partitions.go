@@ -0,0 +1,79 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+)
+
+const amazonImageOwner = "amazon"
+
+type Partition struct {
+	ID            string
+	RegionPrefix  string
+	DefaultRegion string
+	// eks optimized amis are published by regional accounts which are not covered by the 'amazon' alias
+	EksAmiOwners map[string]string
+}
+
+func Partitions() []Partition {
+	return []Partition{
+		{ID: "aws-us-gov", RegionPrefix: "us-gov-", DefaultRegion: "us-gov-west-1", EksAmiOwners: map[string]string{
+			"us-gov-west-1": "013241004608",
+			"us-gov-east-1": "151742754352",
+		}},
+		{ID: "aws-cn", RegionPrefix: "cn-", DefaultRegion: "cn-north-1", EksAmiOwners: map[string]string{
+			"cn-north-1":     "918309763551",
+			"cn-northwest-1": "961992271922",
+		}},
+		{ID: "aws", RegionPrefix: "", DefaultRegion: fallbackRegion},
+	}
+}
+
+func PartitionForRegion(region string) Partition {
+	partitions := Partitions()
+	for _, partition := range partitions {
+		if strings.HasPrefix(region, partition.RegionPrefix) {
+			return partition
+		}
+	}
+
+	return partitions[len(partitions)-1]
+}
+
+func PartitionFromArn(arn string) (Partition, error) {
+	arnSplit := strings.Split(arn, ":")
+	if len(arnSplit) < 6 || arnSplit[0] != "arn" {
+		return Partition{}, fmt.Errorf("arn (%s) is not valid", arn)
+	}
+	for _, partition := range Partitions() {
+		if partition.ID == arnSplit[1] {
+			return partition, nil
+		}
+	}
+
+	return Partition{}, fmt.Errorf("arn (%s) is in unsupported partition (%s)", arn, arnSplit[1])
+}
+
+func (p Partition) HasRegion(region string) bool {
+	return PartitionForRegion(region).ID == p.ID
+}
+
+func (p Partition) ImageOwners(region string) []string {
+	if owner, ok := p.EksAmiOwners[region]; ok {
+		return []string{amazonImageOwner, owner}
+	}
+
+	return []string{amazonImageOwner}
+}
+
+func (p Partition) RoleArnAccountID(roleArn string) (string, error) {
+	accountID, err := AccountIDFromRoleArn(roleArn)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(roleArn, "arn:"+p.ID+":") {
+		return "", fmt.Errorf("role arn (%s) is not in the %s partition", roleArn, p.ID)
+	}
+
+	return accountID, nil
+}
@@ -0,0 +1,197 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	awsLib "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartitionForRegion(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                  string
+		region                string
+		expectedID            string
+		expectedDefaultRegion string
+		expectedOwners        []string
+	}{
+		{name: "commercial region",
+			region:                "eu-west-1",
+			expectedID:            "aws",
+			expectedDefaultRegion: "us-east-1",
+			expectedOwners:        []string{"amazon"},
+		},
+		{name: "commercial region which looks like govcloud one",
+			region:                "us-east-2",
+			expectedID:            "aws",
+			expectedDefaultRegion: "us-east-1",
+			expectedOwners:        []string{"amazon"},
+		},
+		{name: "govcloud region",
+			region:                "us-gov-east-1",
+			expectedID:            "aws-us-gov",
+			expectedDefaultRegion: "us-gov-west-1",
+			expectedOwners:        []string{"amazon", "151742754352"},
+		},
+		{name: "china region",
+			region:                "cn-north-1",
+			expectedID:            "aws-cn",
+			expectedDefaultRegion: "cn-north-1",
+			expectedOwners:        []string{"amazon", "918309763551"},
+		},
+		{name: "unknown china region",
+			region:                "cn-south-1",
+			expectedID:            "aws-cn",
+			expectedDefaultRegion: "cn-north-1",
+			expectedOwners:        []string{"amazon"},
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+
+		output := PartitionForRegion(test.region)
+
+		assert.Equal(t, test.expectedID, output.ID)
+		assert.Equal(t, test.expectedDefaultRegion, output.DefaultRegion)
+		assert.Equal(t, test.expectedOwners, output.ImageOwners(test.region))
+		assert.True(t, output.HasRegion(test.region))
+	}
+}
+
+func TestPartitionFromArn(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		arn           string
+		expectedValue string
+		expectedError bool
+	}{
+		{name: "commercial arn",
+			arn:           "arn:aws:iam::111111111111:role/eks-ng-ami-updater",
+			expectedValue: "aws",
+		},
+		{name: "govcloud arn",
+			arn:           "arn:aws-us-gov:iam::111111111111:role/eks-ng-ami-updater",
+			expectedValue: "aws-us-gov",
+		},
+		{name: "china arn",
+			arn:           "arn:aws-cn:sts::111111111111:assumed-role/eks-ng-ami-updater/session",
+			expectedValue: "aws-cn",
+		},
+		{name: "unsupported partition",
+			arn:           "arn:aws-iso:iam::111111111111:role/eks-ng-ami-updater",
+			expectedError: true,
+		},
+		{name: "empty arn",
+			arn:           "",
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+
+		output, err := PartitionFromArn(test.arn)
+
+		assert.Equal(t, test.expectedValue, output.ID)
+		assert.Equal(t, test.expectedError, err != nil)
+	}
+}
+
+func TestPartitionRoleArnAccountID(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		region        string
+		roleArn       string
+		expectedValue string
+		expectedError bool
+	}{
+		{name: "role arn in the same partition",
+			region:        "us-gov-west-1",
+			roleArn:       "arn:aws-us-gov:iam::111111111111:role/eks-ng-ami-updater",
+			expectedValue: "111111111111",
+		},
+		{name: "role arn in other partition",
+			region:        "cn-north-1",
+			roleArn:       "arn:aws:iam::111111111111:role/eks-ng-ami-updater",
+			expectedError: true,
+		},
+		{name: "commercial role arn is not taken as china one",
+			region:        "eu-west-1",
+			roleArn:       "arn:aws-cn:iam::111111111111:role/eks-ng-ami-updater",
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+
+		output, err := PartitionForRegion(test.region).RoleArnAccountID(test.roleArn)
+
+		assert.Equal(t, test.expectedValue, output)
+		assert.Equal(t, test.expectedError, err != nil)
+	}
+}
+
+func TestGetLatestAmiWithinSsmInPartitions(t *testing.T) {
+	t.Parallel()
+
+	parameter := &ssmTypes.Parameter{Value: awsLib.String("ami-111"), LastModifiedDate: awsLib.Time(time.Date(2024, time.January, 2, 10, 0, 0, 0, time.UTC))}
+	images := &ec2.DescribeImagesOutput{
+		Images: []ec2Types.Image{
+			{ImageId: awsLib.String("ami-111"), OwnerId: awsLib.String("013241004608"), ImageLocation: awsLib.String("013241004608/amazon-eks-node-1.30-v20240101")},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		region        string
+		awsSsm        TestSsm
+		expectedValue string
+		expectedError string
+	}{
+		{name: "ami owned by govcloud eks account is found",
+			region:        "us-gov-west-1",
+			awsSsm:        TestSsm{OutputGetParameter: &ssm.GetParameterOutput{Parameter: parameter}},
+			expectedValue: "ami-111",
+		},
+		{name: "ami owned by govcloud eks account is not trusted in other region",
+			region:        "us-gov-east-1",
+			awsSsm:        TestSsm{OutputGetParameter: &ssm.GetParameterOutput{Parameter: parameter}},
+			expectedError: "ami (ami-111) is not found",
+		},
+		{name: "ami parameter is not published in china",
+			region:        "cn-northwest-1",
+			awsSsm:        TestSsm{ErrGetParameter: &smithy.GenericAPIError{Code: "ParameterNotFound", Message: "parameter not found"}},
+			expectedError: "ami parameter (/aws/service/eks/optimized-ami/1.30/amazon-linux-2/recommended/image_id) is not published in region cn-northwest-1 of the aws-cn partition: api error ParameterNotFound: parameter not found",
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		awsEc2 := TestEc2{OutputImages: images}
+
+		output, err := GetLatestAmiWithinSsm("AL2_x86_64", "1.30", test.region, test.awsSsm, awsEc2, context.Background())
+
+		assert.Equal(t, test.expectedValue, output.ImageID)
+		if test.expectedError != "" {
+			assert.EqualError(t, err, test.expectedError)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+}
@@ -3,12 +3,15 @@ package aws
 import (
 	"context"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/loomhq/eks-ng-ami-updater/pkg/utils"
 	"github.com/rs/zerolog/log"
 )
 
-func GetRegionsToCheck(regionsVar []string, awsEc2 Ec2, ctx context.Context) ([]string, error) {
+const regionNotOptedIn = "not-opted-in"
+
+func GetRegionsToCheck(regionsVar, excludeRegionsVar []string, partition Partition, awsEc2 Ec2, ctx context.Context) ([]string, error) {
 	var regions []string
 	logWithContext := log.Ctx(ctx).With().Str("function", "GetRegionsToCheck").Logger()
 
@@ -16,18 +19,43 @@ func GetRegionsToCheck(regionsVar []string, awsEc2 Ec2, ctx context.Context) ([]
 		logWithContext.Debug().Msg("looking for regions")
 
 		input := &ec2.DescribeRegionsInput{AllRegions: aws.Bool(true)}
-		result, err := awsEc2.DescribeRegions(input)
+		result, err := awsEc2.DescribeRegions(ctx, input)
 		if err != nil {
 			return nil, err
 		}
 
 		for _, v := range result.Regions {
+			if v.OptInStatus != nil && *v.OptInStatus == regionNotOptedIn {
+				logWithContext.Debug().Str("region", *v.RegionName).Str("optInStatus", *v.OptInStatus).Msg("skip region (account is not opted in)")
+
+				continue
+			}
 			regions = append(regions, *v.RegionName)
 		}
 
 		logWithContext.Debug().Strs("regions", regions).Msg("regions have been selected")
 	} else {
-		regions = regionsVar
+		for _, region := range regionsVar {
+			if !partition.HasRegion(region) {
+				logWithContext.Warn().Str("region", region).Str("partition", partition.ID).Msg("skip region (it is not in the partition of the used credentials)")
+
+				continue
+			}
+			regions = append(regions, region)
+		}
+	}
+
+	if len(excludeRegionsVar) > 0 {
+		var allowedRegions []string
+		for _, region := range regions {
+			if utils.Contains(excludeRegionsVar, region) {
+				logWithContext.Debug().Str("region", region).Strs("excludeRegionsVar", excludeRegionsVar).Msg("skip region (excluded by 'exclude-regions' flag)")
+
+				continue
+			}
+			allowedRegions = append(allowedRegions, region)
+		}
+		regions = allowedRegions
 	}
 
 	return regions, nil
@@ -5,8 +5,9 @@ import (
 	"fmt"
 	"testing"
 
-	awsLib "github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/ec2"
+	awsLib "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -14,15 +15,17 @@ func TestGetRegionsToCheck(t *testing.T) {
 	t.Parallel()
 
 	tests := []struct {
-		name          string
-		mockedOutput  ec2.DescribeRegionsOutput
-		regionsVar    []string
-		expectedValue []string
-		expectedError error
+		name              string
+		region            string
+		mockedOutput      ec2.DescribeRegionsOutput
+		regionsVar        []string
+		excludeRegionsVar []string
+		expectedValue     []string
+		expectedError     error
 	}{
 		{name: "regionsVar cli parameter is set #1",
 			mockedOutput: ec2.DescribeRegionsOutput{
-				Regions: []*ec2.Region{
+				Regions: []ec2Types.Region{
 					{RegionName: awsLib.String("us-west-1")},
 					{RegionName: awsLib.String("us-west-2")},
 					{RegionName: awsLib.String("us-west-3")},
@@ -40,7 +43,7 @@ func TestGetRegionsToCheck(t *testing.T) {
 		},
 		{name: "regionsVar cli parameter is not set #1",
 			mockedOutput: ec2.DescribeRegionsOutput{
-				Regions: []*ec2.Region{
+				Regions: []ec2Types.Region{
 					{RegionName: awsLib.String("us-west-1")},
 				},
 			},
@@ -50,7 +53,7 @@ func TestGetRegionsToCheck(t *testing.T) {
 		},
 		{name: "regionsVar cli parameter is not set #2",
 			mockedOutput: ec2.DescribeRegionsOutput{
-				Regions: []*ec2.Region{
+				Regions: []ec2Types.Region{
 					{RegionName: awsLib.String("us-west-1")},
 					{RegionName: awsLib.String("us-west-2")},
 					{RegionName: awsLib.String("us-west-3")},
@@ -60,14 +63,72 @@ func TestGetRegionsToCheck(t *testing.T) {
 			expectedValue: []string{"us-west-1", "us-west-2", "us-west-3"},
 			expectedError: nil,
 		},
+		{name: "not opted in regions are skipped",
+			mockedOutput: ec2.DescribeRegionsOutput{
+				Regions: []ec2Types.Region{
+					{RegionName: awsLib.String("us-west-1"), OptInStatus: awsLib.String("opt-in-not-required")},
+					{RegionName: awsLib.String("af-south-1"), OptInStatus: awsLib.String("not-opted-in")},
+					{RegionName: awsLib.String("ap-east-1"), OptInStatus: awsLib.String("opted-in")},
+				},
+			},
+			regionsVar:    []string{},
+			expectedValue: []string{"us-west-1", "ap-east-1"},
+			expectedError: nil,
+		},
+		{name: "excludeRegionsVar cli parameter is set",
+			mockedOutput: ec2.DescribeRegionsOutput{
+				Regions: []ec2Types.Region{
+					{RegionName: awsLib.String("us-west-1"), OptInStatus: awsLib.String("opt-in-not-required")},
+					{RegionName: awsLib.String("us-west-2"), OptInStatus: awsLib.String("opt-in-not-required")},
+					{RegionName: awsLib.String("eu-west-1"), OptInStatus: awsLib.String("opt-in-not-required")},
+				},
+			},
+			regionsVar:        []string{},
+			excludeRegionsVar: []string{"us-west-2"},
+			expectedValue:     []string{"us-west-1", "eu-west-1"},
+			expectedError:     nil,
+		},
+		{name: "excludeRegionsVar takes precedence over regionsVar",
+			mockedOutput:      ec2.DescribeRegionsOutput{},
+			regionsVar:        []string{"eu-west-1", "eu-west-2"},
+			excludeRegionsVar: []string{"eu-west-1"},
+			expectedValue:     []string{"eu-west-2"},
+			expectedError:     nil,
+		},
+		{name: "govcloud regions are listed",
+			region: "us-gov-west-1",
+			mockedOutput: ec2.DescribeRegionsOutput{
+				Regions: []ec2Types.Region{
+					{RegionName: awsLib.String("us-gov-east-1"), OptInStatus: awsLib.String("opt-in-not-required")},
+					{RegionName: awsLib.String("us-gov-west-1"), OptInStatus: awsLib.String("opt-in-not-required")},
+				},
+			},
+			regionsVar:    []string{},
+			expectedValue: []string{"us-gov-east-1", "us-gov-west-1"},
+			expectedError: nil,
+		},
+		{name: "regionsVar from other partition are skipped in china",
+			region:        "cn-north-1",
+			mockedOutput:  ec2.DescribeRegionsOutput{},
+			regionsVar:    []string{"cn-north-1", "us-gov-west-1", "eu-west-1", "cn-northwest-1"},
+			expectedValue: []string{"cn-north-1", "cn-northwest-1"},
+			expectedError: nil,
+		},
+		{name: "regionsVar from other partition are skipped in commercial",
+			region:        "us-east-1",
+			mockedOutput:  ec2.DescribeRegionsOutput{},
+			regionsVar:    []string{"us-gov-west-1", "eu-west-1"},
+			expectedValue: []string{"eu-west-1"},
+			expectedError: nil,
+		},
 	}
 
 	for _, test := range tests {
 		fmt.Printf("test: %s\n", test.name)
-		awsEc2 := testEc2{OutputRegions: &test.mockedOutput}
+		awsEc2 := TestEc2{OutputRegions: &test.mockedOutput}
 		regionsVar := test.regionsVar
 
-		output, err := GetRegionsToCheck(regionsVar, awsEc2, context.Background())
+		output, err := GetRegionsToCheck(regionsVar, test.excludeRegionsVar, PartitionForRegion(test.region), awsEc2, context.Background())
 
 		assert.Equal(t, test.expectedValue, output)
 		assert.Equal(t, test.expectedError, err)
@@ -0,0 +1,112 @@
+package aws
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+type AmiKey struct {
+	Region  string `json:"region"`
+	AmiType string `json:"amiType"`
+	Version string `json:"version"`
+}
+
+type AmiResolver struct {
+	cache   *AmiCache
+	mutex   sync.Mutex
+	lookups map[AmiKey]*amiLookup
+}
+
+type amiLookup struct {
+	done   chan struct{}
+	record AmiRecord
+	err    error
+}
+
+func NewAmiResolver(cache *AmiCache) *AmiResolver {
+	return &AmiResolver{
+		cache:   cache,
+		lookups: map[AmiKey]*amiLookup{},
+	}
+}
+
+func (r *AmiResolver) Resolve(key AmiKey, clients Clients, ctx context.Context) (AmiRecord, error) {
+	logWithContext := log.Ctx(ctx).With().Str("function", "Resolve").Logger()
+
+	r.mutex.Lock()
+	lookup, ok := r.lookups[key]
+	if ok {
+		r.mutex.Unlock()
+		<-lookup.done
+		logWithContext.Debug().Str("region", key.Region).Str("amiType", key.AmiType).Str("version", key.Version).Msg("ami record is taken from cache")
+
+		return lookup.record, lookup.err
+	}
+	lookup = &amiLookup{done: make(chan struct{})}
+	r.lookups[key] = lookup
+	r.mutex.Unlock()
+
+	// amis are the same for every account, the clients of the first caller's account look the key up for all of them
+	lookup.record, lookup.err = r.lookup(key, clients, ctx)
+	// failed lookups are not kept, a later nodegroup with the same key tries again
+	if lookup.err != nil {
+		r.mutex.Lock()
+		delete(r.lookups, key)
+		r.mutex.Unlock()
+	}
+	close(lookup.done)
+
+	logWithContext.Debug().Str("region", key.Region).Str("amiType", key.AmiType).Str("version", key.Version).
+		Str("imageId", lookup.record.ImageID).Str("release", lookup.record.Release).Msg("ami record is resolved")
+
+	return lookup.record, lookup.err
+}
+
+func (r *AmiResolver) store(key AmiKey, record AmiRecord, err error) {
+	// only prefetched records and parameters missing from the catalog are stored, those errors are final
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.lookups[key]; ok {
+		return
+	}
+
+	lookup := &amiLookup{done: make(chan struct{}), record: record, err: err}
+	close(lookup.done)
+	r.lookups[key] = lookup
+}
+
+func (r *AmiResolver) lookup(key AmiKey, clients Clients, ctx context.Context) (AmiRecord, error) {
+	awsSsm, err := clients.Ssm(key.Region)
+	if err != nil {
+		return AmiRecord{}, err
+	}
+
+	parameter, err := GetAmiSsmParameter(key.AmiType, key.Version, key.Region, awsSsm, ctx)
+	if err != nil {
+		return AmiRecord{}, err
+	}
+
+	if record, ok := r.cache.Get(key, parameter); ok {
+		return record, nil
+	}
+
+	awsEc2, err := clients.Ec2(key.Region)
+	if err != nil {
+		return AmiRecord{}, err
+	}
+
+	image, err := GetLatestAmiWithinEc2(*parameter.Value, key.Region, awsEc2, ctx)
+	if err != nil {
+		return AmiRecord{}, err
+	}
+
+	record, err := NewAmiRecord(key.AmiType, *parameter.Value, *parameter.LastModifiedDate, image)
+	if err == nil {
+		r.cache.Put(key, parameter, record)
+	}
+
+	return record, err
+}
@@ -0,0 +1,131 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	awsLib "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAmiResolverResolve(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name             string
+		keys             []AmiKey
+		expectedSsmCalls int32
+		expectedEc2Calls int32
+	}{
+		{name: "nodegroups sharing region, ami type and version are resolved once",
+			keys: []AmiKey{
+				{Region: "eu-west-1", AmiType: "BOTTLEROCKET_x86_64", Version: "1.24"},
+				{Region: "eu-west-1", AmiType: "BOTTLEROCKET_x86_64", Version: "1.24"},
+				{Region: "eu-west-1", AmiType: "BOTTLEROCKET_x86_64", Version: "1.24"},
+			},
+			expectedSsmCalls: 1,
+			expectedEc2Calls: 1,
+		},
+		{name: "one lookup per distinct key",
+			keys: []AmiKey{
+				{Region: "eu-west-1", AmiType: "BOTTLEROCKET_x86_64", Version: "1.24"},
+				{Region: "eu-west-1", AmiType: "BOTTLEROCKET_x86_64", Version: "1.25"},
+				{Region: "us-west-2", AmiType: "BOTTLEROCKET_x86_64", Version: "1.24"},
+				{Region: "eu-west-1", AmiType: "BOTTLEROCKET_ARM_64", Version: "1.24"},
+				{Region: "eu-west-1", AmiType: "BOTTLEROCKET_x86_64", Version: "1.25"},
+			},
+			expectedSsmCalls: 4,
+			expectedEc2Calls: 4,
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		var ssmCalls, ec2Calls atomic.Int32
+		var waitGroup sync.WaitGroup
+		clients := TestClients{
+			AwsSsm: TestSsm{
+				OutputGetParameter: &ssm.GetParameterOutput{Parameter: &ssmTypes.Parameter{
+					LastModifiedDate: toTimePtr(time.Date(2023, time.January, 30, 10, 0, 0, 0, time.UTC)),
+					Value:            awsLib.String("ami-08a3df9f52daf9b5f"),
+				}},
+				Calls: &ssmCalls,
+			},
+			AwsEc2: TestEc2{
+				OutputImages: &ec2.DescribeImagesOutput{Images: []ec2Types.Image{
+					{ImageLocation: awsLib.String("amazon/bottlerocket-aws-k8s-1.24-x86_64-v1.14.0-9cd59298")},
+				}},
+				Calls: &ec2Calls,
+			},
+		}
+		resolver := NewAmiResolver(nil)
+
+		for _, key := range test.keys {
+			for range 10 {
+				waitGroup.Add(1)
+				go func() {
+					defer waitGroup.Done()
+					record, err := resolver.Resolve(key, clients, context.Background())
+					assert.NoError(t, err)
+					assert.Equal(t, "v1.14.0-9cd59298", record.Release)
+				}()
+			}
+		}
+		waitGroup.Wait()
+
+		assert.Equal(t, test.expectedSsmCalls, ssmCalls.Load())
+		assert.Equal(t, test.expectedEc2Calls, ec2Calls.Load())
+	}
+}
+
+func TestAmiResolverResolveError(t *testing.T) {
+	t.Parallel()
+
+	var ssmCalls atomic.Int32
+	errThrottling := &smithy.GenericAPIError{Code: "ThrottlingException", Message: "rate exceeded"}
+	clients := TestClients{AwsSsm: TestSsm{ErrGetParameter: errThrottling, Calls: &ssmCalls}}
+	resolver := NewAmiResolver(nil)
+	key := AmiKey{Region: "eu-west-1", AmiType: "BOTTLEROCKET_x86_64", Version: "1.24"}
+
+	for range 3 {
+		_, err := resolver.Resolve(key, clients, context.Background())
+		assert.ErrorIs(t, err, errThrottling)
+	}
+
+	// errors are not kept, so every resolve asks ssm again
+	assert.Equal(t, int32(3), ssmCalls.Load())
+}
+
+func TestAmiResolverResolveAccountClients(t *testing.T) {
+	t.Parallel()
+
+	denied := TestClients{AwsSsm: TestSsm{ErrGetParameter: &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized"}}}
+	allowed := TestClients{
+		AwsSsm: TestSsm{OutputGetParameter: &ssm.GetParameterOutput{Parameter: &ssmTypes.Parameter{
+			LastModifiedDate: toTimePtr(time.Date(2023, time.January, 30, 10, 0, 0, 0, time.UTC)),
+			Value:            awsLib.String("ami-08a3df9f52daf9b5f"),
+		}}},
+		AwsEc2: TestEc2{OutputImages: &ec2.DescribeImagesOutput{Images: []ec2Types.Image{
+			{ImageLocation: awsLib.String("amazon/bottlerocket-aws-k8s-1.24-x86_64-v1.14.0-9cd59298")},
+		}}},
+	}
+	resolver := NewAmiResolver(nil)
+	key := AmiKey{Region: "eu-west-1", AmiType: "BOTTLEROCKET_x86_64", Version: "1.24"}
+
+	// the clients of the account asking for the key are used, so another account can resolve it after a failure
+	_, err := resolver.Resolve(key, denied, context.Background())
+	assert.Equal(t, "AccessDeniedException", ErrorCode(err))
+
+	record, err := resolver.Resolve(key, allowed, context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, "v1.14.0-9cd59298", record.Release)
+}
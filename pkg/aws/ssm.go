@@ -1,24 +1,50 @@
 package aws
 
 import (
+	"context"
 	"fmt"
 
-	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
 )
 
 type SSM interface {
-	GetParameter(input *ssm.GetParameterInput) (*ssm.GetParameterOutput, error)
+	GetParameter(ctx context.Context, input *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+	GetParameters(ctx context.Context, input *ssm.GetParametersInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersOutput, error)
 }
 
 type RealSsm struct {
-	Svc *ssm.SSM
+	Svc       *ssm.Client
+	Account   string
+	Region    string
+	Throttler *Throttler
 }
 
-func (t RealSsm) GetParameter(input *ssm.GetParameterInput) (*ssm.GetParameterOutput, error) {
-	result, err := t.Svc.GetParameter(input)
+func (t RealSsm) GetParameter(ctx context.Context, input *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+	var result *ssm.GetParameterOutput
+	err := t.Throttler.Call(ctx, "ssm", t.Account, t.Region, func() error {
+		var err error
+		result, err = t.Svc.GetParameter(ctx, input, optFns...)
+
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("error getting parameters: %w", err)
 	}
 
 	return result, nil
 }
+
+func (t RealSsm) GetParameters(ctx context.Context, input *ssm.GetParametersInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersOutput, error) {
+	var result *ssm.GetParametersOutput
+	err := t.Throttler.Call(ctx, "ssm", t.Account, t.Region, func() error {
+		var err error
+		result, err = t.Svc.GetParameters(ctx, input, optFns...)
+
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting parameters by names: %w", err)
+	}
+
+	return result, nil
+}
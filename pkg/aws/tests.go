@@ -1,28 +1,151 @@
 package aws
 
 import (
-	awsLib "github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/ec2"
-	"github.com/aws/aws-sdk-go/service/eks"
-	"github.com/aws/aws-sdk-go/service/ssm"
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	awsLib "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	eksTypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	organizationsTypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/loomhq/eks-ng-ami-updater/pkg/utils"
 )
 
-type testEks struct {
+type TestEks struct {
 	OutputListClusters      *eks.ListClustersOutput
+	ErrListClusters         error
 	OutputListNodegroups    *eks.ListNodegroupsOutput
+	ErrListNodegroups       error
 	OutputDescribeNodegroup *eks.DescribeNodegroupOutput
+	InputUpdateNodegroup    *eks.UpdateNodegroupVersionInput
+	ErrUpdateNodegroup      error
+	ErrDescribeNodegroup    error
+	OutputListUpdates       *eks.ListUpdatesOutput
+	OutputDescribeUpdate    map[string]*eks.DescribeUpdateOutput
+	Latency                 time.Duration
+	UpdateCalls             *atomic.Int32
+	OnUpdateNodegroup       func(input *eks.UpdateNodegroupVersionInput)
+	Interrupt               context.CancelFunc
 }
 
-type testEc2 struct {
+type TestEc2 struct {
 	OutputRegions *ec2.DescribeRegionsOutput
 	OutputImages  *ec2.DescribeImagesOutput
+	Calls         *atomic.Int32
+}
+
+type TestSsm struct {
+	OutputGetParameter  *ssm.GetParameterOutput
+	OutputGetParameters map[string]ssmTypes.Parameter
+	ErrGetParameter     error
+	Calls               *atomic.Int32
+}
+
+type TestOrganizations struct {
+	Accounts        []organizationsTypes.Account
+	ParentIDs       map[string]string
+	Tags            map[string][]organizationsTypes.Tag
+	ErrListAccounts error
+}
+
+type TestClients struct {
+	Account          string
+	AwsEks           EKS
+	AwsSsm           SSM
+	AwsEc2           Ec2
+	AwsOrganizations Organizations
+	ErrAccountID     error
+	ErrAssumeRole    error
+	// errors of AssumeRole for single role arns
+	ErrAssumeRoleArns map[string]error
+}
+
+func (t TestClients) DefaultRegion() string {
+	return fallbackRegion
+}
+
+func (t TestClients) AccountID(ctx context.Context) (string, error) {
+	return t.Account, t.ErrAccountID
+}
+
+func (t TestClients) AssumeRole(roleArn, externalID string, ctx context.Context) (Clients, error) {
+	if t.ErrAssumeRole != nil {
+		return nil, t.ErrAssumeRole
+	}
+	if err := t.ErrAssumeRoleArns[roleArn]; err != nil {
+		return nil, err
+	}
+
+	accountID, err := AccountIDFromRoleArn(roleArn)
+	if err != nil {
+		return nil, err
+	}
+
+	return TestClients{Account: accountID, AwsEks: t.AwsEks, AwsSsm: t.AwsSsm, AwsEc2: t.AwsEc2, AwsOrganizations: t.AwsOrganizations, ErrAssumeRoleArns: t.ErrAssumeRoleArns}, nil
+}
+
+func (t TestClients) Eks(region string) (EKS, error) {
+	return t.AwsEks, nil
+}
+
+func (t TestClients) Ssm(region string) (SSM, error) {
+	return t.AwsSsm, nil
+}
+
+func (t TestClients) Ec2(region string) (Ec2, error) {
+	return t.AwsEc2, nil
+}
+
+func (t TestClients) Organizations() (Organizations, error) {
+	return t.AwsOrganizations, nil
+}
+
+func (t TestOrganizations) ListAccounts(ctx context.Context, input *organizations.ListAccountsInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsOutput, error) {
+	if t.ErrListAccounts != nil {
+		return nil, t.ErrListAccounts
+	}
+
+	return &organizations.ListAccountsOutput{Accounts: t.Accounts}, nil
+}
+
+func (t TestOrganizations) ListAccountsForParent(ctx context.Context, input *organizations.ListAccountsForParentInput, optFns ...func(*organizations.Options)) (*organizations.ListAccountsForParentOutput, error) {
+	var accounts []organizationsTypes.Account
+	for _, account := range t.Accounts {
+		if t.ParentIDs[*account.Id] == *input.ParentId {
+			accounts = append(accounts, account)
+		}
+	}
+
+	return &organizations.ListAccountsForParentOutput{Accounts: accounts}, nil
+}
+
+func (t TestOrganizations) ListOrganizationalUnitsForParent(ctx context.Context, input *organizations.ListOrganizationalUnitsForParentInput, optFns ...func(*organizations.Options)) (*organizations.ListOrganizationalUnitsForParentOutput, error) {
+	var units []organizationsTypes.OrganizationalUnit
+	for id, parentID := range t.ParentIDs {
+		if strings.HasPrefix(id, "ou-") && parentID == *input.ParentId {
+			units = append(units, organizationsTypes.OrganizationalUnit{Id: awsLib.String(id)})
+		}
+	}
+
+	return &organizations.ListOrganizationalUnitsForParentOutput{OrganizationalUnits: units}, nil
 }
 
-type testSsm struct {
-	OutputGetParameter *ssm.GetParameterOutput
+func (t TestOrganizations) ListTagsForResource(ctx context.Context, input *organizations.ListTagsForResourceInput, optFns ...func(*organizations.Options)) (*organizations.ListTagsForResourceOutput, error) {
+	return &organizations.ListTagsForResourceOutput{Tags: t.Tags[*input.ResourceId]}, nil
 }
 
-func (t testEks) ListClusters(input *eks.ListClustersInput) (*eks.ListClustersOutput, error) {
+func (t TestEks) ListClusters(ctx context.Context, input *eks.ListClustersInput, optFns ...func(*eks.Options)) (*eks.ListClustersOutput, error) {
+	time.Sleep(t.Latency)
+	if t.ErrListClusters != nil {
+		return nil, t.ErrListClusters
+	}
 	output := t.OutputListClusters
 
 	if t.OutputListClusters.NextToken != nil {
@@ -30,14 +153,18 @@ func (t testEks) ListClusters(input *eks.ListClustersInput) (*eks.ListClustersOu
 			t.OutputListClusters.NextToken = awsLib.String("222")
 		} else if *t.OutputListClusters.NextToken == "222" {
 			t.OutputListClusters.NextToken = nil
-			output.Clusters = []*string{t.OutputListClusters.Clusters[0]}
+			output.Clusters = []string{t.OutputListClusters.Clusters[0]}
 		}
 	}
 
 	return output, nil
 }
 
-func (t testEks) ListNodegroups(input *eks.ListNodegroupsInput) (*eks.ListNodegroupsOutput, error) {
+func (t TestEks) ListNodegroups(ctx context.Context, input *eks.ListNodegroupsInput, optFns ...func(*eks.Options)) (*eks.ListNodegroupsOutput, error) {
+	time.Sleep(t.Latency)
+	if t.ErrListNodegroups != nil {
+		return nil, t.ErrListNodegroups
+	}
 	output := t.OutputListNodegroups
 
 	if t.OutputListNodegroups.NextToken != nil {
@@ -45,32 +172,125 @@ func (t testEks) ListNodegroups(input *eks.ListNodegroupsInput) (*eks.ListNodegr
 			t.OutputListNodegroups.NextToken = awsLib.String("222")
 		} else if *t.OutputListNodegroups.NextToken == "222" {
 			t.OutputListNodegroups.NextToken = nil
-			output.Nodegroups = []*string{t.OutputListNodegroups.Nodegroups[0]}
+			output.Nodegroups = []string{t.OutputListNodegroups.Nodegroups[0]}
 		}
 	}
 
 	return output, nil
 }
 
-func (t testEks) DescribeNodegroup(input *eks.DescribeNodegroupInput) (*eks.DescribeNodegroupOutput, error) {
+func (t TestEks) DescribeNodegroup(ctx context.Context, input *eks.DescribeNodegroupInput, optFns ...func(*eks.Options)) (*eks.DescribeNodegroupOutput, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-time.After(t.Latency):
+	}
+	// interrupt cancels the run while a nodegroup is being waited for
+	if t.Interrupt != nil {
+		t.Interrupt()
+
+		return nil, ctx.Err()
+	}
+	if t.ErrDescribeNodegroup != nil {
+		return nil, t.ErrDescribeNodegroup
+	}
 	output := t.OutputDescribeNodegroup
+	if output == nil {
+		output = &eks.DescribeNodegroupOutput{Nodegroup: &eksTypes.Nodegroup{Status: eksTypes.NodegroupStatusActive}}
+	}
 
 	return output, nil
 }
 
-func (t testEc2) DescribeRegions(input *ec2.DescribeRegionsInput) (*ec2.DescribeRegionsOutput, error) {
+func (t TestEks) UpdateNodegroupVersion(ctx context.Context, input *eks.UpdateNodegroupVersionInput, optFns ...func(*eks.Options)) (*eks.UpdateNodegroupVersionOutput, error) {
+	time.Sleep(t.Latency)
+	if t.UpdateCalls != nil {
+		t.UpdateCalls.Add(1)
+	}
+	if t.InputUpdateNodegroup != nil {
+		*t.InputUpdateNodegroup = *input
+	}
+	if t.OnUpdateNodegroup != nil {
+		t.OnUpdateNodegroup(input)
+	}
+
+	return &eks.UpdateNodegroupVersionOutput{}, t.ErrUpdateNodegroup
+}
+func (t TestEks) ListUpdates(ctx context.Context, input *eks.ListUpdatesInput, optFns ...func(*eks.Options)) (*eks.ListUpdatesOutput, error) {
+	return t.OutputListUpdates, nil
+}
+func (t TestEks) DescribeUpdate(ctx context.Context, input *eks.DescribeUpdateInput, optFns ...func(*eks.Options)) (*eks.DescribeUpdateOutput, error) {
+	return t.OutputDescribeUpdate[*input.UpdateId], nil
+}
+
+func (t TestEc2) DescribeRegions(ctx context.Context, input *ec2.DescribeRegionsInput, optFns ...func(*ec2.Options)) (*ec2.DescribeRegionsOutput, error) {
 	var output = t.OutputRegions
 
 	return output, nil
 }
-func (t testEc2) DescribeImages(input *ec2.DescribeImagesInput) (*ec2.DescribeImagesOutput, error) {
+func (t TestEc2) DescribeImages(ctx context.Context, input *ec2.DescribeImagesInput, optFns ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error) {
 	var output = t.OutputImages
+	if t.Calls != nil {
+		t.Calls.Add(1)
+	}
+	if len(input.ImageIds) > 1 || hasImageOwners(t.OutputImages) {
+		output = &ec2.DescribeImagesOutput{}
+		for _, image := range t.OutputImages.Images {
+			if image.ImageId != nil && utils.Contains(input.ImageIds, *image.ImageId) && isImageOwnedBy(image, input.Owners) {
+				output.Images = append(output.Images, image)
+			}
+		}
+	}
 
 	return output, nil
 }
 
-func (t testSsm) GetParameter(input *ssm.GetParameterInput) (*ssm.GetParameterOutput, error) {
+func hasImageOwners(output *ec2.DescribeImagesOutput) bool {
+	for _, image := range output.Images {
+		if image.OwnerId != nil || image.ImageOwnerAlias != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isImageOwnedBy(image ec2Types.Image, owners []string) bool {
+	if image.OwnerId == nil && image.ImageOwnerAlias == nil {
+		return true
+	}
+
+	return (image.OwnerId != nil && utils.Contains(owners, *image.OwnerId)) ||
+		(image.ImageOwnerAlias != nil && utils.Contains(owners, *image.ImageOwnerAlias))
+}
+
+func (t TestSsm) GetParameter(ctx context.Context, input *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
 	var output = t.OutputGetParameter
+	if t.Calls != nil {
+		t.Calls.Add(1)
+	}
+	if t.ErrGetParameter != nil {
+		return nil, t.ErrGetParameter
+	}
+
+	return output, nil
+}
+
+func (t TestSsm) GetParameters(ctx context.Context, input *ssm.GetParametersInput, optFns ...func(*ssm.Options)) (*ssm.GetParametersOutput, error) {
+	if t.Calls != nil {
+		t.Calls.Add(1)
+	}
+
+	output := &ssm.GetParametersOutput{}
+	for _, name := range input.Names {
+		parameter, ok := t.OutputGetParameters[name]
+		if !ok {
+			output.InvalidParameters = append(output.InvalidParameters, name)
+
+			continue
+		}
+		output.Parameters = append(output.Parameters, parameter)
+	}
 
 	return output, nil
 }
@@ -0,0 +1,207 @@
+package aws
+
+import (
+	"context"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/time/rate"
+)
+
+type RetryOptions struct {
+	RateLimit  float64
+	Burst      int
+	MaxRetries int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+}
+
+type ApiStats struct {
+	Calls     int64
+	Retries   int64
+	Throttled time.Duration
+}
+
+type Throttler struct {
+	options  RetryOptions
+	sleep    func(ctx context.Context, d time.Duration) error
+	mutex    sync.Mutex
+	limiters map[string]*rate.Limiter
+	stats    map[string]*ApiStats
+}
+
+func NewThrottler(options RetryOptions) *Throttler {
+	return &Throttler{
+		options:  options,
+		sleep:    sleep,
+		limiters: map[string]*rate.Limiter{},
+		stats:    map[string]*ApiStats{},
+	}
+}
+
+func (t *Throttler) Call(ctx context.Context, service, account, region string, call func() error) error {
+	return t.call(ctx, service, account, region, IsRetryable, call)
+}
+
+func (t *Throttler) CallNonIdempotent(ctx context.Context, service, account, region string, call func() error) error {
+	// a throttled call is rejected before the api acts on it, a server error may come after the change was made
+	return t.call(ctx, service, account, region, IsThrottle, call)
+}
+
+func (t *Throttler) call(ctx context.Context, service, account, region string, retryable func(error) bool, call func() error) error {
+	if t == nil {
+		return call()
+	}
+
+	// api rate limits are applied per account, so is the client-side bucket
+	key := service + "/" + region
+	if account != "" {
+		key = service + "/" + account + "/" + region
+	}
+	limiter := t.limiter(key)
+
+	for attempt := 0; ; attempt++ {
+		reservation := limiter.Reserve()
+		delay := reservation.Delay()
+		if delay > 0 {
+			if err := t.sleep(ctx, delay); err != nil {
+				reservation.Cancel()
+
+				return err
+			}
+		}
+
+		err := call()
+		stats := ApiStats{Calls: 1, Throttled: delay}
+		if attempt > 0 {
+			stats.Retries = 1
+		}
+		t.record(key, stats)
+		if err == nil || attempt >= t.options.MaxRetries || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		backoff := t.backoff(attempt)
+		if err := t.sleep(ctx, backoff); err != nil {
+			return err
+		}
+		t.record(key, ApiStats{Throttled: backoff})
+	}
+}
+
+func (t *Throttler) Stats() map[string]ApiStats {
+	if t == nil {
+		return nil
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	stats := make(map[string]ApiStats, len(t.stats))
+	for key, value := range t.stats {
+		stats[key] = *value
+	}
+
+	return stats
+}
+
+func (t *Throttler) LogSummary(ctx context.Context) {
+	logWithContext := log.Ctx(ctx).With().Str("function", "LogSummary").Logger()
+
+	stats := t.Stats()
+	keys := make([]string, 0, len(stats))
+	for key := range stats {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var total ApiStats
+	for _, key := range keys {
+		value := stats[key]
+		total.Calls += value.Calls
+		total.Retries += value.Retries
+		total.Throttled += value.Throttled
+		if value.Retries > 0 || value.Throttled > 0 {
+			logWithContext.Info().Str("api", key).Int64("calls", value.Calls).Int64("retries", value.Retries).
+				Dur("throttled", value.Throttled).Msg("aws api calls were retried or throttled")
+		}
+	}
+
+	logWithContext.Info().Int64("calls", total.Calls).Int64("retries", total.Retries).Dur("throttled", total.Throttled).Msg("aws api calls summary")
+}
+
+func (t *Throttler) limiter(key string) *rate.Limiter {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if limiter, ok := t.limiters[key]; ok {
+		return limiter
+	}
+
+	limit := rate.Inf
+	if t.options.RateLimit > 0 {
+		limit = rate.Limit(t.options.RateLimit)
+	}
+	t.limiters[key] = rate.NewLimiter(limit, max(t.options.Burst, 1))
+
+	return t.limiters[key]
+}
+
+func (t *Throttler) record(key string, delta ApiStats) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	stats, ok := t.stats[key]
+	if !ok {
+		stats = &ApiStats{}
+		t.stats[key] = stats
+	}
+	stats.Calls += delta.Calls
+	stats.Retries += delta.Retries
+	stats.Throttled += delta.Throttled
+}
+
+func (t *Throttler) backoff(attempt int) time.Duration {
+	if t.options.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := t.options.BaseDelay << min(attempt, 30)
+	if t.options.MaxDelay > 0 && delay > t.options.MaxDelay {
+		delay = t.options.MaxDelay
+	}
+
+	return rand.N(delay) + 1 //nolint:gosec // jitter does not need a secure source
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func IsThrottle(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	return retry.IsErrorThrottles(retry.DefaultThrottles).IsErrorThrottle(err).Bool()
+}
+
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	return IsThrottle(err) || retry.IsErrorRetryables(retry.DefaultRetryables).IsErrorRetryable(err).Bool()
+}
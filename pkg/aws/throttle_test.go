@@ -0,0 +1,225 @@
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"github.com/stretchr/testify/assert"
+)
+
+func responseError(statusCode int) error {
+	return &smithyhttp.ResponseError{
+		Response: &smithyhttp.Response{Response: &http.Response{StatusCode: statusCode}},
+		Err:      errors.New(http.StatusText(statusCode)),
+	}
+}
+
+func TestThrottlerCall(t *testing.T) {
+	t.Parallel()
+
+	throttling := &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}
+	internal := responseError(http.StatusInternalServerError)
+	denied := &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized"}
+
+	tests := []struct {
+		name            string
+		options         RetryOptions
+		nonIdempotent   bool
+		errors          []error
+		expectedCalls   int
+		expectedRetries int64
+		expectedErr     error
+	}{
+		{name: "successful call is not retried",
+			options:         RetryOptions{MaxRetries: 3, BaseDelay: time.Second},
+			errors:          []error{nil},
+			expectedCalls:   1,
+			expectedRetries: 0,
+			expectedErr:     nil,
+		},
+		{name: "throttling error is retried until success",
+			options:         RetryOptions{MaxRetries: 3, BaseDelay: time.Second},
+			errors:          []error{throttling, throttling, nil},
+			expectedCalls:   3,
+			expectedRetries: 2,
+			expectedErr:     nil,
+		},
+		{name: "server error is retried",
+			options:         RetryOptions{MaxRetries: 3, BaseDelay: time.Second},
+			errors:          []error{internal, nil},
+			expectedCalls:   2,
+			expectedRetries: 1,
+			expectedErr:     nil,
+		},
+		{name: "server error is not retried for non idempotent calls",
+			options:         RetryOptions{MaxRetries: 3, BaseDelay: time.Second},
+			nonIdempotent:   true,
+			errors:          []error{internal, nil},
+			expectedCalls:   1,
+			expectedRetries: 0,
+			expectedErr:     internal,
+		},
+		{name: "throttling error is retried for non idempotent calls",
+			options:         RetryOptions{MaxRetries: 3, BaseDelay: time.Second},
+			nonIdempotent:   true,
+			errors:          []error{throttling, nil},
+			expectedCalls:   2,
+			expectedRetries: 1,
+			expectedErr:     nil,
+		},
+		{name: "non retryable error is returned at once",
+			options:         RetryOptions{MaxRetries: 3, BaseDelay: time.Second},
+			errors:          []error{denied, nil},
+			expectedCalls:   1,
+			expectedRetries: 0,
+			expectedErr:     denied,
+		},
+		{name: "last error is returned when retries are exhausted",
+			options:         RetryOptions{MaxRetries: 2, BaseDelay: time.Second},
+			errors:          []error{throttling, throttling, throttling, nil},
+			expectedCalls:   3,
+			expectedRetries: 2,
+			expectedErr:     throttling,
+		},
+		{name: "retries can be disabled",
+			options:         RetryOptions{MaxRetries: 0, BaseDelay: time.Second},
+			errors:          []error{throttling, nil},
+			expectedCalls:   1,
+			expectedRetries: 0,
+			expectedErr:     throttling,
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		var slept time.Duration
+		throttler := NewThrottler(test.options)
+		throttler.sleep = func(ctx context.Context, d time.Duration) error {
+			slept += d
+
+			return nil
+		}
+
+		calls := 0
+		call := throttler.Call
+		if test.nonIdempotent {
+			call = throttler.CallNonIdempotent
+		}
+		err := call(context.Background(), "eks", "", "eu-west-1", func() error {
+			err := test.errors[calls]
+			calls++
+
+			return err
+		})
+
+		stats := throttler.Stats()["eks/eu-west-1"]
+		assert.Equal(t, test.expectedErr, err)
+		assert.Equal(t, test.expectedCalls, calls)
+		assert.Equal(t, int64(test.expectedCalls), stats.Calls)
+		assert.Equal(t, test.expectedRetries, stats.Retries)
+		assert.Equal(t, slept, stats.Throttled)
+		if test.expectedRetries > 0 {
+			assert.Positive(t, stats.Throttled)
+		}
+	}
+}
+
+func TestThrottlerRateLimit(t *testing.T) {
+	t.Parallel()
+
+	var slept time.Duration
+	throttler := NewThrottler(RetryOptions{RateLimit: 1, Burst: 2})
+	throttler.sleep = func(ctx context.Context, d time.Duration) error {
+		slept += d
+
+		return nil
+	}
+
+	for range 3 {
+		assert.NoError(t, throttler.Call(context.Background(), "ssm", "", "eu-west-1", func() error { return nil }))
+	}
+	assert.NoError(t, throttler.Call(context.Background(), "ssm", "", "us-west-2", func() error { return nil }))
+	assert.NoError(t, throttler.Call(context.Background(), "ssm", "111111111111", "eu-west-1", func() error { return nil }))
+
+	stats := throttler.Stats()
+	assert.Equal(t, int64(3), stats["ssm/eu-west-1"].Calls)
+	assert.InDelta(t, time.Second, stats["ssm/eu-west-1"].Throttled, float64(100*time.Millisecond))
+	assert.Equal(t, time.Duration(0), stats["ssm/us-west-2"].Throttled)
+	assert.Equal(t, time.Duration(0), stats["ssm/111111111111/eu-west-1"].Throttled)
+	assert.Equal(t, slept, stats["ssm/eu-west-1"].Throttled)
+}
+
+func TestThrottlerCanceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	throttler := NewThrottler(RetryOptions{MaxRetries: 5, BaseDelay: time.Hour})
+
+	calls := 0
+	err := throttler.Call(ctx, "ec2", "", "eu-west-1", func() error {
+		calls++
+		cancel()
+
+		return &smithy.GenericAPIError{Code: "RequestLimitExceeded", Message: "Request limit exceeded"}
+	})
+
+	assert.Equal(t, 1, calls)
+	assert.Error(t, err)
+	assert.Equal(t, int64(0), throttler.Stats()["ec2/eu-west-1"].Retries)
+}
+
+func TestThrottlerBackoff(t *testing.T) {
+	t.Parallel()
+
+	throttler := NewThrottler(RetryOptions{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second})
+
+	for attempt := range 10 {
+		ceiling := min(100*time.Millisecond<<attempt, time.Second)
+		backoff := throttler.backoff(attempt)
+		assert.Positive(t, backoff)
+		assert.LessOrEqual(t, backoff, ceiling)
+	}
+	assert.Equal(t, time.Duration(0), NewThrottler(RetryOptions{}).backoff(3))
+}
+
+func TestIsRetryable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "throttling exception",
+			err:      fmt.Errorf("error listing clusters: %w", &smithy.GenericAPIError{Code: "ThrottlingException", Message: "Rate exceeded"}),
+			expected: true,
+		},
+		{name: "ec2 request limit exceeded",
+			err:      &smithy.GenericAPIError{Code: "RequestLimitExceeded", Message: "Request limit exceeded"},
+			expected: true,
+		},
+		{name: "server error",
+			err:      responseError(http.StatusServiceUnavailable),
+			expected: true,
+		},
+		{name: "access denied",
+			err:      &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized"},
+			expected: false,
+		},
+		{name: "plain error",
+			err:      errors.New("boom"),
+			expected: false,
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		assert.Equal(t, test.expected, IsRetryable(test.err))
+	}
+}
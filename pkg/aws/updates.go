@@ -0,0 +1,42 @@
+package aws
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	eksTypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/rs/zerolog/log"
+)
+
+func GetInProgressUpdates(nodegroup NodeGroup, awsEks EKS, ctx context.Context) ([]eksTypes.Update, error) {
+	var updates []eksTypes.Update
+	logWithContext := log.Ctx(ctx).With().Str("function", "GetInProgressUpdates").Logger()
+
+	paginator := eks.NewListUpdatesPaginator(awsEks, &eks.ListUpdatesInput{
+		Name:          &nodegroup.ClusterName,
+		NodegroupName: &nodegroup.NodegroupName,
+	})
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, updateID := range output.UpdateIds {
+			described, err := awsEks.DescribeUpdate(ctx, &eks.DescribeUpdateInput{
+				Name:          &nodegroup.ClusterName,
+				NodegroupName: &nodegroup.NodegroupName,
+				UpdateId:      &updateID,
+			})
+			if err != nil {
+				return nil, err
+			}
+			if described.Update.Status == eksTypes.UpdateStatusInProgress {
+				updates = append(updates, *described.Update)
+			}
+		}
+	}
+
+	logWithContext.Debug().Str("region", nodegroup.Region).Str("cluster", nodegroup.ClusterName).Str("nodegroup", nodegroup.NodegroupName).Int("updates", len(updates)).Msg("in progress updates have been found")
+
+	return updates, nil
+}
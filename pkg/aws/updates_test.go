@@ -0,0 +1,48 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	awsLib "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	eksTypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetInProgressUpdates(t *testing.T) {
+	t.Parallel()
+
+	inProgress := eksTypes.Update{Id: awsLib.String("update-2"), Type: eksTypes.UpdateTypeVersionUpdate, Status: eksTypes.UpdateStatusInProgress}
+	described := map[string]*eks.DescribeUpdateOutput{
+		"update-1": {Update: &eksTypes.Update{Id: awsLib.String("update-1"), Type: eksTypes.UpdateTypeVersionUpdate, Status: eksTypes.UpdateStatusSuccessful}},
+		"update-2": {Update: &inProgress},
+	}
+
+	tests := []struct {
+		name          string
+		updateIDs     []string
+		expectedValue []eksTypes.Update
+	}{
+		{name: "only in progress updates are returned",
+			updateIDs:     []string{"update-1", "update-2"},
+			expectedValue: []eksTypes.Update{inProgress},
+		},
+		{name: "no updates in progress",
+			updateIDs:     []string{"update-1"},
+			expectedValue: nil,
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		awsEks := TestEks{OutputListUpdates: &eks.ListUpdatesOutput{UpdateIds: test.updateIDs}, OutputDescribeUpdate: described}
+		nodegroup := NodeGroup{Region: "eu-west-1", ClusterName: "cluster-1", NodegroupName: "ng-1"}
+
+		output, err := GetInProgressUpdates(nodegroup, awsEks, context.Background())
+
+		assert.Equal(t, test.expectedValue, output)
+		assert.NoError(t, err)
+	}
+}
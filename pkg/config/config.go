@@ -0,0 +1,186 @@
+package config
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+
+	"github.com/loomhq/eks-ng-ami-updater/pkg/aws"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ReleaseLatest  = "latest"
+	DefaultsScope  = "defaults"
+	overridesScope = "overrides"
+)
+
+//go:embed schema.json
+var Schema []byte
+
+var releaseVersionPattern = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+-[0-9a-z]+$`)
+
+type Policy struct {
+	SkipNewerThanDays *uint  `yaml:"skipNewerThanDays,omitempty" json:"skipNewerThanDays,omitempty"`
+	Release           string `yaml:"release,omitempty"           json:"release,omitempty"`
+	Force             *bool  `yaml:"force,omitempty"             json:"force,omitempty"`
+	Concurrency       *int   `yaml:"concurrency,omitempty"       json:"concurrency,omitempty"`
+}
+
+type Match struct {
+	Account   string `yaml:"account,omitempty"   json:"account,omitempty"`
+	Region    string `yaml:"region,omitempty"    json:"region,omitempty"`
+	Cluster   string `yaml:"cluster,omitempty"   json:"cluster,omitempty"`
+	Nodegroup string `yaml:"nodegroup,omitempty" json:"nodegroup,omitempty"`
+}
+
+type Override struct {
+	Name   string `yaml:"name,omitempty" json:"name,omitempty"`
+	Match  Match  `yaml:"match"          json:"match"`
+	Policy `yaml:",inline"`
+}
+
+type Config struct {
+	Defaults  Policy     `yaml:"defaults,omitempty"  json:"defaults,omitempty"`
+	Overrides []Override `yaml:"overrides,omitempty" json:"overrides,omitempty"`
+}
+
+type Resolution struct {
+	Policy           Policy
+	Blocks           []string
+	ConcurrencyScope string
+}
+
+func Load(configFile string) (*Config, error) {
+	if configFile == "" {
+		return &Config{}, nil
+	}
+
+	content, err := os.ReadFile(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %w", err)
+	}
+
+	return Parse(content)
+}
+
+func Parse(content []byte) (*Config, error) {
+	var config Config
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	err := decoder.Decode(&config)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error parsing config: %w", err)
+	}
+
+	err = config.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+func (c *Config) Validate() error {
+	var errs []error
+
+	errs = append(errs, c.Defaults.validate(DefaultsScope)...)
+	for i, override := range c.Overrides {
+		scope := override.scope(i)
+		if override.Match == (Match{}) {
+			errs = append(errs, fmt.Errorf("%s: match has to set at least one of account, region, cluster or nodegroup", scope))
+		}
+		for _, pattern := range []string{override.Match.Account, override.Match.Region, override.Match.Cluster, override.Match.Nodegroup} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				errs = append(errs, fmt.Errorf("%s: match (%s) is not a valid pattern: %w", scope, pattern, err))
+			}
+		}
+		errs = append(errs, override.Policy.validate(scope)...)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (p Policy) validate(scope string) []error {
+	var errs []error
+
+	if p.Release != "" && p.Release != ReleaseLatest && !releaseVersionPattern.MatchString(p.Release) {
+		errs = append(errs, fmt.Errorf("%s: release (%s) has to be '%s' or a release version (eg. '1.29.0-20240202')", scope, p.Release, ReleaseLatest))
+	}
+	if p.Concurrency != nil && *p.Concurrency < 1 {
+		errs = append(errs, fmt.Errorf("%s: concurrency (%d) has to be at least 1", scope, *p.Concurrency))
+	}
+
+	return errs
+}
+
+// TakeFlagDefaults removes the defaults which also have a flag from the
+// config and returns them, so the flag or its environment variable can win.
+func (c *Config) TakeFlagDefaults() Policy {
+	defaults := Policy{SkipNewerThanDays: c.Defaults.SkipNewerThanDays}
+	c.Defaults.SkipNewerThanDays = nil
+
+	return defaults
+}
+
+func (c *Config) Resolve(nodegroup aws.NodeGroup, base Policy) Resolution {
+	resolution := Resolution{Policy: base, ConcurrencyScope: DefaultsScope}
+	resolution.apply(c.Defaults, DefaultsScope)
+
+	for i, override := range c.Overrides {
+		if override.Match.matches(nodegroup) {
+			resolution.apply(override.Policy, override.scope(i))
+		}
+	}
+
+	return resolution
+}
+
+func (r *Resolution) apply(policy Policy, scope string) {
+	if policy == (Policy{}) {
+		return
+	}
+
+	if policy.SkipNewerThanDays != nil {
+		r.Policy.SkipNewerThanDays = policy.SkipNewerThanDays
+	}
+	if policy.Release != "" {
+		r.Policy.Release = policy.Release
+	}
+	if policy.Force != nil {
+		r.Policy.Force = policy.Force
+	}
+	if policy.Concurrency != nil {
+		r.Policy.Concurrency = policy.Concurrency
+		r.ConcurrencyScope = scope
+	}
+	r.Blocks = append(r.Blocks, scope)
+}
+
+func (m Match) matches(nodegroup aws.NodeGroup) bool {
+	return matches(m.Account, nodegroup.AccountID) && matches(m.Region, nodegroup.Region) &&
+		matches(m.Cluster, nodegroup.ClusterName) && matches(m.Nodegroup, nodegroup.NodegroupName)
+}
+
+func matches(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	matched, err := path.Match(pattern, value)
+
+	return err == nil && matched
+}
+
+func (o Override) scope(i int) string {
+	if o.Name != "" {
+		return fmt.Sprintf("%s[%d] (%s)", overridesScope, i, o.Name)
+	}
+
+	return fmt.Sprintf("%s[%d]", overridesScope, i)
+}
@@ -0,0 +1,203 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/loomhq/eks-ng-ami-updater/pkg/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		content       string
+		expectedValue *Config
+		expectedError string
+	}{
+		{name: "empty config",
+			content:       "",
+			expectedValue: &Config{},
+		},
+		{name: "defaults and overrides",
+			content: `
+defaults:
+  skipNewerThanDays: 3
+overrides:
+  - name: production
+    match:
+      cluster: prod-*
+    skipNewerThanDays: 7
+    concurrency: 1
+`,
+			expectedValue: &Config{
+				Defaults: Policy{SkipNewerThanDays: toPtr(uint(3))},
+				Overrides: []Override{
+					{Name: "production", Match: Match{Cluster: "prod-*"}, Policy: Policy{SkipNewerThanDays: toPtr(uint(7)), Concurrency: toPtr(1)}},
+				},
+			},
+		},
+		{name: "unknown field",
+			content:       "defaults:\n  skipNewerThan: 3\n",
+			expectedError: "field skipNewerThan not found",
+		},
+		{name: "invalid release",
+			content:       "defaults:\n  release: newest\n",
+			expectedError: "defaults: release (newest) has to be 'latest' or a release version",
+		},
+		{name: "invalid concurrency",
+			content:       "overrides:\n  - match:\n      region: eu-*\n    concurrency: 0\n",
+			expectedError: "overrides[0]: concurrency (0) has to be at least 1",
+		},
+		{name: "override without match",
+			content:       "overrides:\n  - name: all\n    force: true\n",
+			expectedError: "overrides[0] (all): match has to set at least one of account, region, cluster or nodegroup",
+		},
+		{name: "invalid match pattern",
+			content:       "overrides:\n  - match:\n      cluster: prod-[\n",
+			expectedError: "overrides[0]: match (prod-[) is not a valid pattern",
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+
+		output, err := Parse([]byte(test.content))
+
+		assert.Equal(t, test.expectedValue, output)
+		if test.expectedError != "" {
+			assert.ErrorContains(t, err, test.expectedError)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	t.Parallel()
+
+	config := &Config{
+		Defaults: Policy{SkipNewerThanDays: toPtr(uint(1)), Concurrency: toPtr(10)},
+		Overrides: []Override{
+			{Name: "production", Match: Match{Cluster: "prod-*"}, Policy: Policy{SkipNewerThanDays: toPtr(uint(7)), Concurrency: toPtr(1)}},
+			{Match: Match{Nodegroup: "*-gpu"}, Policy: Policy{Release: "1.29.0-20240202"}},
+			{Match: Match{Account: "111111111111", Region: "us-*"}, Policy: Policy{Force: toPtr(true)}},
+		},
+	}
+	base := Policy{SkipNewerThanDays: toPtr(uint(0)), Release: ReleaseLatest, Force: toPtr(false)}
+
+	tests := []struct {
+		name          string
+		nodegroup     aws.NodeGroup
+		expectedValue Resolution
+	}{
+		{name: "only defaults match",
+			nodegroup: aws.NodeGroup{AccountID: "111111111111", Region: "eu-west-1", ClusterName: "staging-1", NodegroupName: "ng-1"},
+			expectedValue: Resolution{
+				Policy:           Policy{SkipNewerThanDays: toPtr(uint(1)), Release: ReleaseLatest, Force: toPtr(false), Concurrency: toPtr(10)},
+				Blocks:           []string{"defaults"},
+				ConcurrencyScope: "defaults",
+			},
+		},
+		{name: "many overrides match",
+			nodegroup: aws.NodeGroup{AccountID: "111111111111", Region: "us-west-2", ClusterName: "prod-1", NodegroupName: "ng-gpu"},
+			expectedValue: Resolution{
+				Policy:           Policy{SkipNewerThanDays: toPtr(uint(7)), Release: "1.29.0-20240202", Force: toPtr(true), Concurrency: toPtr(1)},
+				Blocks:           []string{"defaults", "overrides[0] (production)", "overrides[1]", "overrides[2]"},
+				ConcurrencyScope: "overrides[0] (production)",
+			},
+		},
+		{name: "all match fields have to match",
+			nodegroup: aws.NodeGroup{AccountID: "222222222222", Region: "us-west-2", ClusterName: "staging-1", NodegroupName: "ng-1"},
+			expectedValue: Resolution{
+				Policy:           Policy{SkipNewerThanDays: toPtr(uint(1)), Release: ReleaseLatest, Force: toPtr(false), Concurrency: toPtr(10)},
+				Blocks:           []string{"defaults"},
+				ConcurrencyScope: "defaults",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+
+		output := config.Resolve(test.nodegroup, base)
+
+		assert.Equal(t, test.expectedValue, output)
+	}
+
+	assert.Equal(t, Resolution{Policy: base, ConcurrencyScope: "defaults"}, (&Config{}).Resolve(aws.NodeGroup{}, base))
+}
+
+func TestTakeFlagDefaults(t *testing.T) {
+	t.Parallel()
+
+	config := &Config{Defaults: Policy{SkipNewerThanDays: toPtr(uint(7)), Release: ReleaseLatest}}
+
+	defaults := config.TakeFlagDefaults()
+
+	assert.Equal(t, Policy{SkipNewerThanDays: toPtr(uint(7))}, defaults)
+	assert.Equal(t, Policy{Release: ReleaseLatest}, config.Defaults)
+}
+
+func TestLoadExample(t *testing.T) {
+	t.Parallel()
+
+	config, err := Load("../../examples/config.yaml")
+
+	assert.NoError(t, err)
+	assert.Len(t, config.Overrides, 3)
+
+	_, err = Load("../../examples/missing.yaml")
+	assert.Error(t, err)
+}
+
+func TestSchema(t *testing.T) {
+	t.Parallel()
+
+	var schema struct {
+		Properties map[string]any `json:"properties"`
+		Defs       struct {
+			Policy struct {
+				Properties map[string]any `json:"properties"`
+			} `json:"policy"`
+			Match struct {
+				Properties map[string]any `json:"properties"`
+			} `json:"match"`
+		} `json:"$defs"`
+	}
+	err := json.Unmarshal(Schema, &schema)
+	assert.NoError(t, err)
+
+	assert.ElementsMatch(t, yamlKeys(Config{}), keys(schema.Properties))
+	assert.ElementsMatch(t, yamlKeys(Policy{}), keys(schema.Defs.Policy.Properties))
+	assert.ElementsMatch(t, yamlKeys(Match{}), keys(schema.Defs.Match.Properties))
+}
+
+func yamlKeys(value any) []string {
+	var fields []string
+
+	valueType := reflect.TypeOf(value)
+	for i := range valueType.NumField() {
+		fields = append(fields, strings.Split(valueType.Field(i).Tag.Get("yaml"), ",")[0])
+	}
+
+	return fields
+}
+
+func keys(values map[string]any) []string {
+	var output []string
+	for key := range values {
+		output = append(output, key)
+	}
+
+	return output
+}
+
+func toPtr[T any](value T) *T {
+	return &value
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const (
+	TagPrefix            = "eks-ng-ami-updater/"
+	TagEnabled           = TagPrefix + "enabled"
+	TagSkipNewerThanDays = TagPrefix + "skip-newer-than-days"
+	TagRelease           = TagPrefix + "release"
+	TagForce             = TagPrefix + "force"
+	TagsScope            = "tags"
+)
+
+func TagPolicy(tags map[string]string) (Policy, map[string]string, error) {
+	var policy Policy
+	var errs []error
+	overrides := map[string]string{}
+
+	if value, ok := tags[TagSkipNewerThanDays]; ok {
+		days, err := strconv.ParseUint(value, 10, 0)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: tag %s (%s) has to be a number of days", TagsScope, TagSkipNewerThanDays, value))
+		} else {
+			skipNewerThanDays := uint(days)
+			policy.SkipNewerThanDays = &skipNewerThanDays
+			overrides[TagSkipNewerThanDays] = value
+		}
+	}
+	if value, ok := tags[TagRelease]; ok {
+		policy.Release = value
+		overrides[TagRelease] = value
+	}
+	if value, ok := tags[TagForce]; ok {
+		force, err := strconv.ParseBool(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: tag %s (%s) has to be true or false", TagsScope, TagForce, value))
+		} else {
+			policy.Force = &force
+			overrides[TagForce] = value
+		}
+	}
+	errs = append(errs, policy.validate(TagsScope)...)
+
+	return policy, overrides, errors.Join(errs...)
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTagPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name              string
+		tags              map[string]string
+		expectedPolicy    Policy
+		expectedOverrides map[string]string
+		expectedError     string
+	}{
+		{name: "no override tags",
+			tags:              map[string]string{"env": "production", TagEnabled: "true"},
+			expectedOverrides: map[string]string{},
+		},
+		{name: "every override tag",
+			tags:              map[string]string{TagSkipNewerThanDays: "3", TagRelease: "1.29.0-20240202", TagForce: "true"},
+			expectedPolicy:    Policy{SkipNewerThanDays: toPtr(uint(3)), Release: "1.29.0-20240202", Force: toPtr(true)},
+			expectedOverrides: map[string]string{TagSkipNewerThanDays: "3", TagRelease: "1.29.0-20240202", TagForce: "true"},
+		},
+		{name: "invalid number of days",
+			tags:          map[string]string{TagSkipNewerThanDays: "week"},
+			expectedError: "tags: tag eks-ng-ami-updater/skip-newer-than-days (week) has to be a number of days",
+		},
+		{name: "invalid release",
+			tags:          map[string]string{TagRelease: "newest"},
+			expectedError: "tags: release (newest) has to be 'latest' or a release version",
+		},
+		{name: "invalid force",
+			tags:          map[string]string{TagForce: "yes"},
+			expectedError: "tags: tag eks-ng-ami-updater/force (yes) has to be true or false",
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		policy, overrides, err := TagPolicy(test.tags)
+		if test.expectedError != "" {
+			assert.ErrorContains(t, err, test.expectedError)
+
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, test.expectedPolicy, policy)
+		assert.Equal(t, test.expectedOverrides, overrides)
+	}
+}
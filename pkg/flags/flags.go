@@ -1,33 +1,305 @@
 package flags
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
 	"strings"
+	"text/tabwriter"
+	"time"
 )
 
-func Setup() (bool, bool, uint, []string, []string, string) {
-	var debug bool
-	var dryrun bool
-	var skipNewerThanDays uint
-	var tag string
-	var regions []string
-	var nodegroups []string
-
-	flag.BoolVar(&debug, "debug", false, "set log level to debug (eg. '--debug=true')")
-	flag.BoolVar(&dryrun, "dryrun", false, "set dryrun mode (eg. '--dryrun=true')")
-	flag.UintVar(&skipNewerThanDays, "skip-newer-than-days", 0, "skip ami update if the latest available ami was published in less than provided number of days (eg. '--skip-newer-than-days=7')")
-	flag.StringVar(&tag, "tag", "", "update amis only for nodegroups within this tag (eg. '--tag=env:production')")
-	flag.Func("nodegroups", "update amis for (only specified here) nodegroups (eg. '--nodegroups=eu-west-1:cluster-1:ngMain,eu-west-2:clusterStage:nodegroupStage1')", func(s string) error {
-		nodegroups = strings.Split(s, ",")
-
-		return nil
+const (
+	CommandPlan      = "plan"
+	CommandApply     = "apply"
+	CommandStatus    = "status"
+	CommandInventory = "inventory"
+	CommandHistory   = "history"
+	CommandExplain   = "explain"
+	CommandValidate  = "validate"
+	CommandConfig    = "config"
+	exitCodeUsage    = 2
+	envPrefix        = "EKS_NG_AMI_UPDATER_"
+
+	skipNewerThanDaysFlag = "skip-newer-than-days"
+)
+
+type Source string
+
+const (
+	SourceDefault Source = "default"
+	SourceConfig  Source = "config"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+type Options struct {
+	Command              string
+	Args                 []string
+	ConfigFile           string        `flag:"config"`
+	Debug                bool          `flag:"debug"`
+	Dryrun               bool          `flag:"dryrun"`
+	Strict               bool          `flag:"strict"`
+	NoCache              bool          `flag:"no-cache"`
+	CacheFile            string        `flag:"cache-file"`
+	CacheTTL             time.Duration `flag:"cache-ttl"`
+	HistoryFile          string        `flag:"history-file"`
+	HistoryLimit         int           `flag:"history-limit"`
+	PlanFile             string        `flag:"plan"`
+	PlanMaxAge           time.Duration `flag:"plan-max-age"`
+	SkipNewerThanDays    uint          `flag:"skip-newer-than-days"`
+	DiscoveryConcurrency int           `flag:"discovery-concurrency"`
+	MaxRunDuration       time.Duration `flag:"max-run-duration"`
+	UpdateStartCutoff    time.Duration `flag:"update-start-cutoff"`
+	UpdateWaitTimeout    time.Duration `flag:"update-wait-timeout"`
+	UpdateWaitMinDelay   time.Duration `flag:"update-wait-min-delay"`
+	UpdateWaitMaxDelay   time.Duration `flag:"update-wait-max-delay"`
+	ApiRateLimit         float64       `flag:"api-rate-limit"`
+	ApiBurst             int           `flag:"api-burst"`
+	ApiMaxRetries        int           `flag:"api-max-retries"`
+	ApiRetryBaseDelay    time.Duration `flag:"api-retry-base-delay"`
+	ApiRetryMaxDelay     time.Duration `flag:"api-retry-max-delay"`
+	EksEndpointURL       string        `flag:"eks-endpoint-url"`
+	SsmEndpointURL       string        `flag:"ssm-endpoint-url"`
+	Ec2EndpointURL       string        `flag:"ec2-endpoint-url"`
+	StsEndpointURL       string        `flag:"sts-endpoint-url"`
+	OrgEndpointURL       string        `flag:"organizations-endpoint-url"`
+	UseFIPSEndpoint      bool          `flag:"use-fips-endpoint"`
+	UseDualStackEndpoint bool          `flag:"use-dualstack-endpoint"`
+	CABundle             string        `flag:"ca-bundle"`
+	Tag                  string        `flag:"tag"`
+	TagSelector          string        `flag:"tag-selector"`
+	ExcludeTagSelector   string        `flag:"exclude-tag-selector"`
+	LabelSelector        string        `flag:"label-selector"`
+	ExcludeLabelSelector string        `flag:"exclude-label-selector"`
+	TaintSelector        string        `flag:"taint-selector"`
+	ExcludeTaintSelector string        `flag:"exclude-taint-selector"`
+	UpdateFirstSelector  string        `flag:"update-first-selector"`
+	Filter               string        `flag:"filter"`
+	OptIn                bool          `flag:"opt-in"`
+	ExternalID           string        `flag:"external-id"`
+	RoleArns             []string      `flag:"role-arns"`
+	Organization         bool          `flag:"organization"`
+	OrganizationTag      string        `flag:"organization-tag"`
+	OrganizationUnits    []string      `flag:"organization-units"`
+	RoleArnTemplate      string        `flag:"role-arn-template"`
+	Regions              []string      `flag:"regions"`
+	ExcludeRegions       []string      `flag:"exclude-regions"`
+	Nodegroups           []string      `flag:"nodegroups"`
+	Sources              map[string]Source
+}
+
+func Setup() Options {
+	flag.CommandLine.Usage = usage
+	options, err := Parse(flag.CommandLine, os.Args[1:], os.LookupEnv)
+	if err != nil {
+		fmt.Fprintln(flag.CommandLine.Output(), err)
+		os.Exit(exitCodeUsage)
+	}
+
+	return options
+}
+
+func usage() {
+	output := flag.CommandLine.Output()
+	fmt.Fprintf(output, "Usage: %s [command] [flags]\n\nCommands:\n", os.Args[0])
+	fmt.Fprintln(output, "  apply        update amis of the nodegroups which are ready for it or of a '--plan' file (default, 'plan' with '--dryrun=true')")
+	fmt.Fprintln(output, "  plan         show the nodegroups which are ready for an ami update without updating them, and save them to a '--plan' file")
+	fmt.Fprintln(output, "  status       show ami updates which are in progress in EKS")
+	fmt.Fprintln(output, "  inventory    list every nodegroup with its ami release and the latest one")
+	fmt.Fprintln(output, "  history      show the runs recorded in '--history-file'")
+	fmt.Fprintln(output, "  explain      show every check which decided whether the given 'region:cluster:nodegroup' nodegroups are updated")
+	fmt.Fprintln(output, "  validate     check the '--config' file")
+	fmt.Fprintln(output, "  config print show the effective flag values and where they come from")
+	fmt.Fprintln(output, "\nFlags:")
+	flag.PrintDefaults()
+}
+
+func Parse(flagSet *flag.FlagSet, args []string, lookupEnv func(string) (string, bool)) (Options, error) {
+	var options Options
+
+	defineRunFlags(flagSet, &options)
+	defineApiFlags(flagSet, &options)
+	defineSelectionFlags(flagSet, &options)
+	flagSet.VisitAll(func(f *flag.Flag) {
+		f.Usage += fmt.Sprintf(" [env %s]", EnvName(f.Name))
+	})
+
+	var positional []string
+	for {
+		err := flagSet.Parse(args)
+		if err != nil {
+			return Options{}, fmt.Errorf("error parsing flags: %w", err)
+		}
+		args = flagSet.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional, args = append(positional, args[0]), args[1:]
+	}
+	if len(positional) > 0 {
+		options.Command, options.Args = positional[0], positional[1:]
+	}
+
+	err := applyEnv(flagSet, &options, lookupEnv)
+	if err != nil {
+		return Options{}, err
+	}
+
+	return options, nil
+}
+
+func applyEnv(flagSet *flag.FlagSet, options *Options, lookupEnv func(string) (string, bool)) error {
+	var errs []error
+
+	options.Sources = map[string]Source{}
+	flagSet.Visit(func(f *flag.Flag) {
+		options.Sources[f.Name] = SourceFlag
 	})
-	flag.Func("regions", "update amis for all nodegroups from those regions only (eg. '--regions=eu-west-1,us-west-1')", func(s string) error {
-		regions = strings.Split(s, ",")
+	flagSet.VisitAll(func(f *flag.Flag) {
+		if _, ok := options.Sources[f.Name]; ok {
+			return
+		}
+		options.Sources[f.Name] = SourceDefault
 
-		return nil
+		value, ok := lookupEnv(EnvName(f.Name))
+		if !ok || value == "" {
+			return
+		}
+		err := f.Value.Set(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid value %q for %s: %w", value, EnvName(f.Name), err))
+
+			return
+		}
+		options.Sources[f.Name] = SourceEnv
 	})
-	flag.Parse()
 
-	return debug, dryrun, skipNewerThanDays, regions, nodegroups, tag
+	return errors.Join(errs...)
+}
+
+// ApplyConfigDefaults sets the defaults of the config file which also have a
+// flag, unless the flag or its environment variable was set.
+func (o *Options) ApplyConfigDefaults(skipNewerThanDays *uint) {
+	if skipNewerThanDays != nil && o.Sources[skipNewerThanDaysFlag] == SourceDefault {
+		o.SkipNewerThanDays = *skipNewerThanDays
+		o.Sources[skipNewerThanDaysFlag] = SourceConfig
+	}
+}
+
+func (o Options) Print(w io.Writer) error {
+	var rows []string
+
+	value := reflect.ValueOf(o)
+	for i := range value.NumField() {
+		name := value.Type().Field(i).Tag.Get("flag")
+		if name == "" {
+			continue
+		}
+		rows = append(rows, fmt.Sprintf("--%s\t%s\t%s\t%s", name, EnvName(name), formatValue(value.Field(i)), o.Sources[name]))
+	}
+	sort.Strings(rows)
+
+	writer := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "FLAG\tENV\tVALUE\tSOURCE")
+	for _, row := range rows {
+		fmt.Fprintln(writer, row)
+	}
+
+	return writer.Flush()
+}
+
+func formatValue(value reflect.Value) string {
+	output := fmt.Sprint(value.Interface())
+	if list, ok := value.Interface().([]string); ok {
+		output = strings.Join(list, ",")
+	}
+	if output == "" {
+		return `""`
+	}
+
+	return output
+}
+
+func EnvName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+func defineRunFlags(flagSet *flag.FlagSet, options *Options) {
+	flagSet.StringVar(&options.ConfigFile, "config", "", "read per account, region, cluster or nodegroup update policies from this yaml file (eg. '--config=/etc/eks-ng-ami-updater/config.yaml')")
+	flagSet.BoolVar(&options.Debug, "debug", false, "set log level to debug (eg. '--debug=true')")
+	flagSet.BoolVar(&options.Dryrun, "dryrun", false, "set dryrun mode (eg. '--dryrun=true')")
+	flagSet.BoolVar(&options.Strict, "strict", false, "stop the whole run on the first discovery or decision error instead of skipping the affected region, cluster or nodegroup (eg. '--strict=true')")
+	flagSet.StringVar(&options.CacheFile, "cache-file", "", "keep resolved amis in this file between runs, eg. on a mounted volume (eg. '--cache-file=/var/cache/eks-ng-ami-updater/amis.json')")
+	flagSet.DurationVar(&options.CacheTTL, "cache-ttl", 24*time.Hour, "maximum age of an ami cache entry (eg. '--cache-ttl=6h')")
+	flagSet.StringVar(&options.HistoryFile, "history-file", "", "append a record of every applied run to this file and read it with the 'history' command (eg. '--history-file=/var/cache/eks-ng-ami-updater/history.jsonl')")
+	flagSet.IntVar(&options.HistoryLimit, "history-limit", 20, "number of the latest runs shown by the 'history' command, 0 shows all (eg. '--history-limit=5')")
+	flagSet.StringVar(&options.PlanFile, "plan", "", "save the plan to this json file with the 'plan' command and update exactly the nodegroups from it with the 'apply' command (eg. '--plan=plan.json')")
+	flagSet.DurationVar(&options.PlanMaxAge, "plan-max-age", 24*time.Hour, "refuse to apply a '--plan' file created longer ago than this, 0 disables the check (eg. '--plan-max-age=4h')")
+	flagSet.BoolVar(&options.NoCache, "no-cache", false, "bypass the ami cache file (eg. '--no-cache=true')")
+	flagSet.UintVar(&options.SkipNewerThanDays, "skip-newer-than-days", 0, "skip ami update if the latest available ami was published in less than provided number of days (eg. '--skip-newer-than-days=7')")
+	flagSet.IntVar(&options.DiscoveryConcurrency, "discovery-concurrency", 10, "maximum number of discovery api calls in flight, shared by all regions, clusters and nodegroups (eg. '--discovery-concurrency=20')")
+	flagSet.DurationVar(&options.MaxRunDuration, "max-run-duration", 0, "cancel the whole run after this time, 0 disables the deadline (eg. '--max-run-duration=2h')")
+	flagSet.DurationVar(&options.UpdateStartCutoff, "update-start-cutoff", 10*time.Minute, "do not start new nodegroup updates when less than this time is left before '--max-run-duration' (eg. '--update-start-cutoff=30m')")
+	flagSet.DurationVar(&options.UpdateWaitTimeout, "update-wait-timeout", 40*time.Minute, "maximum time to wait for a nodegroup to become active after the ami update is started (eg. '--update-wait-timeout=1h')")
+	flagSet.DurationVar(&options.UpdateWaitMinDelay, "update-wait-min-delay", 30*time.Second, "minimum delay between nodegroup status checks while waiting for the ami update (eg. '--update-wait-min-delay=15s')")
+	flagSet.DurationVar(&options.UpdateWaitMaxDelay, "update-wait-max-delay", 2*time.Minute, "maximum delay between nodegroup status checks while waiting for the ami update (eg. '--update-wait-max-delay=5m')")
+}
+
+func defineApiFlags(flagSet *flag.FlagSet, options *Options) {
+	flagSet.Float64Var(&options.ApiRateLimit, "api-rate-limit", 10, "maximum aws api requests per second for each service and region, 0 disables the limit (eg. '--api-rate-limit=5')")
+	flagSet.IntVar(&options.ApiBurst, "api-burst", 20, "number of aws api requests allowed above the rate limit in a burst (eg. '--api-burst=10')")
+	flagSet.IntVar(&options.ApiMaxRetries, "api-max-retries", 5, "retry aws api calls failed with throttling or transient errors up to this many times (eg. '--api-max-retries=8')")
+	flagSet.DurationVar(&options.ApiRetryBaseDelay, "api-retry-base-delay", 200*time.Millisecond, "base delay of the jittered exponential backoff between aws api retries (eg. '--api-retry-base-delay=500ms')")
+	flagSet.DurationVar(&options.ApiRetryMaxDelay, "api-retry-max-delay", 20*time.Second, "maximum delay between aws api retries (eg. '--api-retry-max-delay=30s')")
+	flagSet.StringVar(&options.EksEndpointURL, "eks-endpoint-url", "", "send eks api calls to this url instead of the default endpoint, '{{.Region}}' is replaced by the region of the call and is required unless '--regions' sets a single region (eg. '--eks-endpoint-url=https://vpce-0123.eks.{{.Region}}.vpce.amazonaws.com')")
+	flagSet.StringVar(&options.SsmEndpointURL, "ssm-endpoint-url", "", "send ssm api calls to this url instead of the default endpoint, '{{.Region}}' is replaced by the region of the call and is required unless '--regions' sets a single region (eg. '--ssm-endpoint-url=https://vpce-0123.ssm.{{.Region}}.vpce.amazonaws.com')")
+	flagSet.StringVar(&options.Ec2EndpointURL, "ec2-endpoint-url", "", "send ec2 api calls to this url instead of the default endpoint, '{{.Region}}' is replaced by the region of the call and is required unless '--regions' sets a single region (eg. '--ec2-endpoint-url=https://vpce-0123.ec2.{{.Region}}.vpce.amazonaws.com')")
+	flagSet.StringVar(&options.StsEndpointURL, "sts-endpoint-url", "", "send sts api calls to this url instead of the default endpoint, also AWS_ENDPOINT_URL_STS (eg. '--sts-endpoint-url=https://sts.eu-west-1.amazonaws.com')")
+	flagSet.StringVar(&options.OrgEndpointURL, "organizations-endpoint-url", "", "send organizations api calls to this url instead of the default endpoint, also AWS_ENDPOINT_URL_ORGANIZATIONS (eg. '--organizations-endpoint-url=http://localhost:4566')")
+	flagSet.BoolVar(&options.UseFIPSEndpoint, "use-fips-endpoint", false, "use fips endpoints for every aws api call, also AWS_USE_FIPS_ENDPOINT (eg. '--use-fips-endpoint=true')")
+	flagSet.BoolVar(&options.UseDualStackEndpoint, "use-dualstack-endpoint", false, "use dual-stack (ipv4 and ipv6) endpoints for every aws api call, also AWS_USE_DUALSTACK_ENDPOINT (eg. '--use-dualstack-endpoint=true')")
+	flagSet.StringVar(&options.CABundle, "ca-bundle", "", "trust certificates from this pem file when calling aws endpoints, also AWS_CA_BUNDLE (eg. '--ca-bundle=/etc/ssl/certs/proxy-ca.pem')")
+}
+
+func defineSelectionFlags(flagSet *flag.FlagSet, options *Options) {
+	flagSet.StringVar(&options.Tag, "tag", "", "update amis only for nodegroups within this tag (eg. '--tag=env:production')")
+	flagSet.StringVar(&options.TagSelector, "tag-selector", "", "update amis only for nodegroups whose tags match all requirements of this selector, with '=', '!=', 'in', 'notin', 'key' (exists) and '!key' (does not exist) (eg. '--tag-selector=env in (prod,stage),team')")
+	flagSet.StringVar(&options.ExcludeTagSelector, "exclude-tag-selector", "", "skip nodegroups whose tags match all requirements of this selector (eg. '--exclude-tag-selector=skip-ami-update')")
+	flagSet.StringVar(&options.LabelSelector, "label-selector", "", "update amis only for nodegroups whose kubernetes labels match all requirements of this selector (eg. '--label-selector=role=system')")
+	flagSet.StringVar(&options.ExcludeLabelSelector, "exclude-label-selector", "", "skip nodegroups whose kubernetes labels match all requirements of this selector (eg. '--exclude-label-selector=workload in (batch,ml)')")
+	flagSet.StringVar(&options.TaintSelector, "taint-selector", "", "update amis only for nodegroups whose kubernetes taints, written as 'key=value:Effect', match all requirements of this selector (eg. '--taint-selector=!dedicated')")
+	flagSet.StringVar(&options.ExcludeTaintSelector, "exclude-taint-selector", "", "skip nodegroups whose kubernetes taints, written as 'key=value:Effect', match all requirements of this selector (eg. '--exclude-taint-selector=dedicated=gpu:NoSchedule')")
+	flagSet.StringVar(&options.UpdateFirstSelector, "update-first-selector", "", "update nodegroups whose kubernetes labels match all requirements of this selector before starting the others (eg. '--update-first-selector=role=system')")
+	flagSet.StringVar(&options.Filter, "filter", "", "update amis only for nodegroups matching this cel expression over the 'ng' nodegroup object (eg. '--filter=ng.tags[\"env\"] in [\"prod\", \"preprod\"] && ng.capacityType == \"ON_DEMAND\"')")
+	flagSet.BoolVar(&options.OptIn, "opt-in", false, "update amis only for nodegroups tagged 'eks-ng-ami-updater/enabled=true', nodegroups tagged 'eks-ng-ami-updater/enabled=false' are always skipped (eg. '--opt-in=true')")
+	flagSet.StringVar(&options.ExternalID, "external-id", "", "external id passed when assuming the '--role-arns' roles (eg. '--external-id=eks-ng-ami-updater')")
+	flagSet.Var((*listValue)(&options.RoleArns), "role-arns", "assume those roles and update amis in each of their accounts instead of the current one (eg. '--role-arns=arn:aws:iam::111111111111:role/eks-ng-ami-updater,arn:aws:iam::222222222222:role/eks-ng-ami-updater')")
+	flagSet.BoolVar(&options.Organization, "organization", false, "list active accounts of the aws organization and update amis in each of them by assuming the '--role-arn-template' role (eg. '--organization=true')")
+	flagSet.StringVar(&options.OrganizationTag, "organization-tag", "", "select only organization accounts with this tag in the key:value format (eg. '--organization-tag=eks-ng-ami-updater:enabled')")
+	flagSet.Var((*listValue)(&options.OrganizationUnits), "organization-units", "select only organization accounts from those organizational units and units nested in them (eg. '--organization-units=ou-ab12-11111111,ou-ab12-22222222')")
+	flagSet.StringVar(&options.RoleArnTemplate, "role-arn-template", "arn:{{.Partition}}:iam::{{.AccountID}}:role/eks-ng-ami-updater", "role assumed in each organization account (eg. '--role-arn-template=arn:{{.Partition}}:iam::{{.AccountID}}:role/automation/eks-ng-ami-updater')")
+	flagSet.Var((*listValue)(&options.Nodegroups), "nodegroups", "update amis for (only specified here) nodegroups, prefixed with the account id when '--role-arns' lists more than one role (eg. '--nodegroups=eu-west-1:cluster-1:ngMain,111111111111:eu-west-2:clusterStage:nodegroupStage1')")
+	flagSet.Var((*listValue)(&options.Regions), "regions", "update amis for all nodegroups from those regions only (eg. '--regions=eu-west-1,us-west-1')")
+	flagSet.Var((*listValue)(&options.ExcludeRegions), "exclude-regions", "skip all nodegroups from those regions (eg. '--exclude-regions=ap-east-1,me-south-1')")
+}
+
+type listValue []string
+
+func (l *listValue) String() string {
+	if l == nil {
+		return ""
+	}
+
+	return strings.Join(*l, ",")
+}
+
+func (l *listValue) Set(s string) error {
+	*l = strings.Split(s, ",")
+
+	return nil
 }
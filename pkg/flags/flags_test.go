@@ -0,0 +1,191 @@
+package flags
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		args            []string
+		env             map[string]string
+		expectedCommand string
+		expectedArgs    []string
+		expectedValue   func(options Options) any
+		expected        any
+		expectedSources map[string]Source
+		expectedError   string
+	}{
+		{name: "default is used without flag and env",
+			expectedValue:   func(options Options) any { return options.ApiBurst },
+			expected:        20,
+			expectedSources: map[string]Source{"api-burst": SourceDefault},
+		},
+		{name: "env replaces default",
+			env:             map[string]string{"EKS_NG_AMI_UPDATER_API_BURST": "5"},
+			expectedValue:   func(options Options) any { return options.ApiBurst },
+			expected:        5,
+			expectedSources: map[string]Source{"api-burst": SourceEnv},
+		},
+		{name: "flag replaces env",
+			args:            []string{"--api-burst=7"},
+			env:             map[string]string{"EKS_NG_AMI_UPDATER_API_BURST": "5"},
+			expectedValue:   func(options Options) any { return options.ApiBurst },
+			expected:        7,
+			expectedSources: map[string]Source{"api-burst": SourceFlag},
+		},
+		{name: "empty env is ignored",
+			env:             map[string]string{"EKS_NG_AMI_UPDATER_DRYRUN": ""},
+			expectedValue:   func(options Options) any { return options.Dryrun },
+			expected:        false,
+			expectedSources: map[string]Source{"dryrun": SourceDefault},
+		},
+		{name: "list flags are read from env",
+			env:             map[string]string{"EKS_NG_AMI_UPDATER_REGIONS": "eu-west-1,us-west-2"},
+			expectedValue:   func(options Options) any { return options.Regions },
+			expected:        []string{"eu-west-1", "us-west-2"},
+			expectedSources: map[string]Source{"regions": SourceEnv},
+		},
+		{name: "duration flags are read from env",
+			env:             map[string]string{"EKS_NG_AMI_UPDATER_MAX_RUN_DURATION": "2h"},
+			expectedValue:   func(options Options) any { return options.MaxRunDuration },
+			expected:        2 * time.Hour,
+			expectedSources: map[string]Source{"max-run-duration": SourceEnv},
+		},
+		{name: "command and flags in any order",
+			args:            []string{"--debug", "config", "print", "--dryrun"},
+			expectedCommand: CommandConfig,
+			expectedArgs:    []string{"print"},
+			expectedValue:   func(options Options) any { return options.Debug && options.Dryrun },
+			expected:        true,
+			expectedSources: map[string]Source{"debug": SourceFlag, "dryrun": SourceFlag},
+		},
+		{name: "invalid env value",
+			env:           map[string]string{"EKS_NG_AMI_UPDATER_API_BURST": "many"},
+			expectedError: "invalid value \"many\" for EKS_NG_AMI_UPDATER_API_BURST",
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+		flagSet.SetOutput(io.Discard)
+
+		options, err := Parse(flagSet, test.args, func(name string) (string, bool) {
+			value, ok := test.env[name]
+
+			return value, ok
+		})
+
+		if test.expectedError != "" {
+			assert.ErrorContains(t, err, test.expectedError)
+
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, test.expectedCommand, options.Command)
+		assert.Equal(t, test.expectedArgs, options.Args)
+		assert.Equal(t, test.expected, test.expectedValue(options))
+		for name, source := range test.expectedSources {
+			assert.Equal(t, source, options.Sources[name], name)
+		}
+	}
+}
+
+func TestPrint(t *testing.T) {
+	t.Parallel()
+
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	options, err := Parse(flagSet, []string{"--regions=eu-west-1,us-west-2"}, func(name string) (string, bool) {
+		return "3", name == "EKS_NG_AMI_UPDATER_SKIP_NEWER_THAN_DAYS"
+	})
+	assert.NoError(t, err)
+
+	var output bytes.Buffer
+	err = options.Print(&output)
+	assert.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	assert.Len(t, lines, 1+flagCount(flagSet))
+	assert.Equal(t, []string{"FLAG", "ENV", "VALUE", "SOURCE"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"--regions", "EKS_NG_AMI_UPDATER_REGIONS", "eu-west-1,us-west-2", "flag"}, strings.Fields(findLine(lines, "--regions ")))
+	assert.Equal(t, []string{"--skip-newer-than-days", "EKS_NG_AMI_UPDATER_SKIP_NEWER_THAN_DAYS", "3", "env"}, strings.Fields(findLine(lines, "--skip-newer-than-days ")))
+	assert.Equal(t, []string{"--tag", "EKS_NG_AMI_UPDATER_TAG", `""`, "default"}, strings.Fields(findLine(lines, "--tag ")))
+}
+
+func TestApplyConfigDefaults(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                      string
+		skipNewerThanDays         *uint
+		source                    Source
+		expectedSkipNewerThanDays uint
+		expectedSource            Source
+	}{
+		{name: "config replaces default",
+			skipNewerThanDays:         toPtr(uint(7)),
+			source:                    SourceDefault,
+			expectedSkipNewerThanDays: 7,
+			expectedSource:            SourceConfig,
+		},
+		{name: "env wins over config",
+			skipNewerThanDays:         toPtr(uint(7)),
+			source:                    SourceEnv,
+			expectedSkipNewerThanDays: 3,
+			expectedSource:            SourceEnv,
+		},
+		{name: "flag wins over config",
+			skipNewerThanDays:         toPtr(uint(7)),
+			source:                    SourceFlag,
+			expectedSkipNewerThanDays: 3,
+			expectedSource:            SourceFlag,
+		},
+		{name: "config without the value",
+			source:                    SourceDefault,
+			expectedSkipNewerThanDays: 3,
+			expectedSource:            SourceDefault,
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		options := Options{SkipNewerThanDays: 3, Sources: map[string]Source{skipNewerThanDaysFlag: test.source}}
+
+		options.ApplyConfigDefaults(test.skipNewerThanDays)
+
+		assert.Equal(t, test.expectedSkipNewerThanDays, options.SkipNewerThanDays)
+		assert.Equal(t, test.expectedSource, options.Sources[skipNewerThanDaysFlag])
+	}
+}
+
+func flagCount(flagSet *flag.FlagSet) int {
+	count := 0
+	flagSet.VisitAll(func(*flag.Flag) { count++ })
+
+	return count
+}
+
+func findLine(lines []string, prefix string) string {
+	for _, line := range lines {
+		if strings.HasPrefix(line, prefix) {
+			return line
+		}
+	}
+
+	return ""
+}
+
+func toPtr[T any](value T) *T {
+	return &value
+}
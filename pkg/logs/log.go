@@ -2,17 +2,17 @@ package logs
 
 import (
 	"context"
-	"os"
+	"io"
 
 	"github.com/rs/zerolog"
 )
 
-func Setup(debug bool) context.Context {
+func Setup(debug bool, output io.Writer) context.Context {
 	if debug {
 		zerolog.SetGlobalLevel(zerolog.DebugLevel)
 	} else {
 		zerolog.SetGlobalLevel(zerolog.InfoLevel)
 	}
 
-	return zerolog.New(os.Stdout).WithContext(context.Background())
+	return zerolog.New(output).WithContext(context.Background())
 }
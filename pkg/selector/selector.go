@@ -0,0 +1,169 @@
+package selector
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+type Operator string
+
+const (
+	OperatorEquals       Operator = "="
+	OperatorNotEquals    Operator = "!="
+	OperatorIn           Operator = "in"
+	OperatorNotIn        Operator = "notin"
+	OperatorExists       Operator = "exists"
+	OperatorDoesNotExist Operator = "!exists"
+)
+
+type Requirement struct {
+	Key      string
+	Operator Operator
+	Values   []string
+}
+
+type Selector []Requirement
+
+var setRequirementPattern = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+
+func Parse(s string) (Selector, error) {
+	var selector Selector
+
+	terms, err := splitTerms(s)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, term := range terms {
+		requirement, err := parseRequirement(term)
+		if err != nil {
+			return nil, fmt.Errorf("selector (%s) is invalid: %w", s, err)
+		}
+		selector = append(selector, requirement)
+	}
+
+	return selector, nil
+}
+
+func (s Selector) FirstMismatch(values map[string][]string) (Requirement, bool) {
+	for _, requirement := range s {
+		if !requirement.Matches(values) {
+			return requirement, true
+		}
+	}
+
+	return Requirement{}, false
+}
+
+func (s Selector) String() string {
+	terms := make([]string, 0, len(s))
+	for _, requirement := range s {
+		terms = append(terms, requirement.String())
+	}
+
+	return strings.Join(terms, ",")
+}
+
+func (r Requirement) Matches(values map[string][]string) bool {
+	keyValues, exists := values[r.Key]
+	hasValue := func(value string) bool { return slices.Contains(r.Values, value) }
+
+	// like in kubernetes, a missing key matches the negative operators,
+	// a key with several values matches the positive operators when one of them does and the negative ones when none does
+	switch r.Operator {
+	case OperatorEquals, OperatorIn:
+		return slices.ContainsFunc(keyValues, hasValue)
+	case OperatorNotEquals, OperatorNotIn:
+		return !slices.ContainsFunc(keyValues, hasValue)
+	case OperatorExists:
+		return exists
+	case OperatorDoesNotExist:
+		return !exists
+	default:
+		return false
+	}
+}
+
+func (r Requirement) String() string {
+	switch r.Operator {
+	case OperatorEquals, OperatorNotEquals:
+		return r.Key + string(r.Operator) + r.Values[0]
+	case OperatorIn, OperatorNotIn:
+		return fmt.Sprintf("%s %s (%s)", r.Key, r.Operator, strings.Join(r.Values, ","))
+	case OperatorDoesNotExist:
+		return "!" + r.Key
+	default:
+		return r.Key
+	}
+}
+
+func splitTerms(s string) ([]string, error) {
+	var terms []string
+
+	depth, start := 0, 0
+	for i, char := range s {
+		switch char {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("selector (%s) has an unexpected ')'", s)
+			}
+		case ',':
+			if depth == 0 {
+				terms = append(terms, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("selector (%s) has an unclosed '('", s)
+	}
+	terms = append(terms, s[start:])
+
+	for i, term := range terms {
+		terms[i] = strings.TrimSpace(term)
+		if terms[i] == "" {
+			return nil, fmt.Errorf("selector (%s) has an empty requirement", s)
+		}
+	}
+
+	return terms, nil
+}
+
+func parseRequirement(term string) (Requirement, error) {
+	var requirement Requirement
+
+	switch {
+	case setRequirementPattern.MatchString(term):
+		match := setRequirementPattern.FindStringSubmatch(term)
+		requirement = Requirement{Key: match[1], Operator: Operator(match[2])}
+		for _, value := range strings.Split(match[3], ",") {
+			requirement.Values = append(requirement.Values, strings.TrimSpace(value))
+		}
+	case strings.HasPrefix(term, "!"):
+		requirement = Requirement{Key: strings.TrimSpace(term[1:]), Operator: OperatorDoesNotExist}
+	case strings.Contains(term, "!="):
+		key, value, _ := strings.Cut(term, "!=")
+		requirement = Requirement{Key: strings.TrimSpace(key), Operator: OperatorNotEquals, Values: []string{strings.TrimSpace(value)}}
+	case strings.Contains(term, "="):
+		key, value, _ := strings.Cut(strings.Replace(term, "==", "=", 1), "=")
+		requirement = Requirement{Key: strings.TrimSpace(key), Operator: OperatorEquals, Values: []string{strings.TrimSpace(value)}}
+	default:
+		requirement = Requirement{Key: term, Operator: OperatorExists}
+	}
+
+	if requirement.Key == "" || strings.ContainsAny(requirement.Key, " \t(),=!") {
+		return Requirement{}, fmt.Errorf("key (%s) of requirement (%s) is invalid", requirement.Key, term)
+	}
+	for _, value := range requirement.Values {
+		if strings.ContainsAny(value, "(),=!") {
+			return Requirement{}, fmt.Errorf("value (%s) of requirement (%s) is invalid", value, term)
+		}
+	}
+
+	return requirement, nil
+}
@@ -0,0 +1,142 @@
+package selector
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		selector      string
+		expectedValue Selector
+		expectedError string
+	}{
+		{name: "every operator",
+			selector: "env in (prod, stage),tier notin (frontend),team,!skip-ami-update,owner=alice,cost-center!=42",
+			expectedValue: Selector{
+				{Key: "env", Operator: OperatorIn, Values: []string{"prod", "stage"}},
+				{Key: "tier", Operator: OperatorNotIn, Values: []string{"frontend"}},
+				{Key: "team", Operator: OperatorExists},
+				{Key: "skip-ami-update", Operator: OperatorDoesNotExist},
+				{Key: "owner", Operator: OperatorEquals, Values: []string{"alice"}},
+				{Key: "cost-center", Operator: OperatorNotEquals, Values: []string{"42"}},
+			},
+		},
+		{name: "double equal sign and spaces",
+			selector:      " env == prod , eks:nodegroup-name ",
+			expectedValue: Selector{{Key: "env", Operator: OperatorEquals, Values: []string{"prod"}}, {Key: "eks:nodegroup-name", Operator: OperatorExists}},
+		},
+		{name: "unclosed set",
+			selector:      "env in (prod,stage",
+			expectedError: "has an unclosed '('",
+		},
+		{name: "empty requirement",
+			selector:      "env=prod,,team",
+			expectedError: "has an empty requirement",
+		},
+		{name: "invalid key",
+			selector:      "=prod",
+			expectedError: "key () of requirement (=prod) is invalid",
+		},
+		{name: "set without operator",
+			selector:      "env (prod)",
+			expectedError: "key (env (prod)) of requirement (env (prod)) is invalid",
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+
+		output, err := Parse(test.selector)
+
+		if test.expectedError != "" {
+			assert.ErrorContains(t, err, test.expectedError)
+
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, test.expectedValue, output)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	t.Parallel()
+
+	tags := map[string][]string{"env": {"prod"}, "team": {"platform"}, "tier": {""}}
+
+	tests := []struct {
+		name          string
+		selector      string
+		expectedValue bool
+	}{
+		{name: "equal", selector: "env=prod", expectedValue: true},
+		{name: "equal with another value", selector: "env=stage", expectedValue: false},
+		{name: "not equal with missing key", selector: "owner!=alice", expectedValue: true},
+		{name: "not equal with the same value", selector: "env!=prod", expectedValue: false},
+		{name: "in", selector: "env in (prod,stage)", expectedValue: true},
+		{name: "in with missing key", selector: "owner in (alice)", expectedValue: false},
+		{name: "notin with missing key", selector: "owner notin (alice)", expectedValue: true},
+		{name: "notin", selector: "env notin (prod)", expectedValue: false},
+		{name: "exists with empty value", selector: "tier", expectedValue: true},
+		{name: "does not exist", selector: "!skip-ami-update", expectedValue: true},
+		{name: "does not exist with existing key", selector: "!team", expectedValue: false},
+		{name: "all requirements have to match", selector: "env=prod,team=data", expectedValue: false},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		selector, err := Parse(test.selector)
+		assert.NoError(t, err)
+
+		_, hasMismatch := selector.FirstMismatch(tags)
+
+		assert.Equal(t, test.expectedValue, !hasMismatch)
+		assert.Equal(t, selector, mustParse(t, selector.String()))
+	}
+}
+
+func TestMatchesSeveralValues(t *testing.T) {
+	t.Parallel()
+
+	taints := map[string][]string{"dedicated": {"gpu:NoSchedule", "gpu:NoExecute"}, "spot": {":PreferNoSchedule"}}
+
+	tests := []struct {
+		name          string
+		selector      string
+		expectedValue bool
+	}{
+		{name: "equal with the first value", selector: "dedicated=gpu:NoSchedule", expectedValue: true},
+		{name: "equal with the second value", selector: "dedicated=gpu:NoExecute", expectedValue: true},
+		{name: "equal with none of the values", selector: "dedicated=gpu:PreferNoSchedule", expectedValue: false},
+		{name: "not equal with one of the values", selector: "dedicated!=gpu:NoExecute", expectedValue: false},
+		{name: "not equal with none of the values", selector: "dedicated!=ml:NoSchedule", expectedValue: true},
+		{name: "in", selector: "dedicated in (ml:NoSchedule,gpu:NoExecute)", expectedValue: true},
+		{name: "notin with one of the values", selector: "dedicated notin (gpu:NoExecute)", expectedValue: false},
+		{name: "exists", selector: "dedicated", expectedValue: true},
+		{name: "does not exist with missing key", selector: "!batch", expectedValue: true},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		selector, err := Parse(test.selector)
+		assert.NoError(t, err)
+
+		_, hasMismatch := selector.FirstMismatch(taints)
+
+		assert.Equal(t, test.expectedValue, !hasMismatch)
+	}
+}
+
+func mustParse(t *testing.T, s string) Selector {
+	t.Helper()
+
+	selector, err := Parse(s)
+	assert.NoError(t, err)
+
+	return selector
+}
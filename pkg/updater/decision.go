@@ -0,0 +1,242 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	eksTypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/loomhq/eks-ng-ami-updater/pkg/aws"
+	"github.com/loomhq/eks-ng-ami-updater/pkg/config"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	CheckStatus               = "status"
+	CheckEnabled              = "enabled"
+	CheckTag                  = "tag"
+	CheckTagSelector          = "tag-selector"
+	CheckExcludeTagSelector   = "exclude-tag-selector"
+	CheckLabelSelector        = "label-selector"
+	CheckExcludeLabelSelector = "exclude-label-selector"
+	CheckTaintSelector        = "taint-selector"
+	CheckExcludeTaintSelector = "exclude-taint-selector"
+	CheckFilter               = "filter"
+	CheckPinnedRelease        = "pinned-release"
+	CheckSameVersion          = "same-version"
+	CheckAge                  = "age"
+)
+
+type Check struct {
+	Name    string
+	Inputs  map[string]string
+	Verdict Verdict
+	Reason  string
+}
+
+type Decision struct {
+	Nodegroup aws.NodeGroup
+	Checks    []Check
+	Verdict   Verdict
+	Update    *Update
+	Err       error
+	// tag overrides of the config policy, keyed by tag
+	TagOverrides map[string]string
+	policy       nodegroupPolicy
+}
+
+type statusFilter struct{}
+
+type enabledFilter struct {
+	optIn bool
+}
+
+type tagFilter struct {
+	tag string
+}
+
+type pinnedReleaseFilter struct{}
+
+type sameVersionFilter struct{}
+
+type ageFilter struct{}
+
+func (d *Decision) record(check Check, ctx context.Context) {
+	logWithContext := log.Ctx(ctx).With().Str("function", "record").Logger()
+
+	d.Checks = append(d.Checks, check)
+	logWithContext.Debug().Str("region", d.Nodegroup.Region).Str("cluster", d.Nodegroup.ClusterName).Str("nodegroup", d.Nodegroup.NodegroupName).
+		Str("check", check.Name).Interface("inputs", check.Inputs).Str("verdict", string(check.Verdict)).Str("reason", check.Reason).Msg("nodegroup check is done")
+}
+
+func (statusFilter) Name() string {
+	return CheckStatus
+}
+
+func (statusFilter) Filter(candidate *Candidate, ctx context.Context) (FilterResult, error) {
+	status := candidate.Description.Status
+	result := FilterResult{Inputs: map[string]string{"status": string(status)}}
+
+	// degraded nodegroups are often fixed by an update, creating and updating ones can be updated in a later run
+	switch status {
+	case eksTypes.NodegroupStatusActive, eksTypes.NodegroupStatusDegraded:
+		result.Verdict, result.Reason = VerdictKeep, fmt.Sprintf("nodegroup status %s allows an ami update", status)
+	case eksTypes.NodegroupStatusCreating, eksTypes.NodegroupStatusUpdating:
+		result.Verdict, result.Reason = VerdictDefer, fmt.Sprintf("nodegroup status %s does not allow an ami update yet", status)
+	default:
+		result.Verdict, result.Reason = VerdictSkip, fmt.Sprintf("nodegroup status %s does not allow an ami update", status)
+	}
+
+	return result, nil
+}
+
+func (enabledFilter) Name() string {
+	return CheckEnabled
+}
+
+func (f enabledFilter) Filter(candidate *Candidate, ctx context.Context) (FilterResult, error) {
+	value, hasTag := candidate.Description.Tags[config.TagEnabled]
+	result := FilterResult{Verdict: VerdictKeep, Inputs: map[string]string{"tag": value, "optIn": strconv.FormatBool(f.optIn)}}
+	if !hasTag {
+		result.Reason = "nodegroup is not opted out"
+		if f.optIn {
+			result.Verdict, result.Reason = VerdictSkip, fmt.Sprintf("nodegroup is not opted in with the '%s=true' tag", config.TagEnabled)
+		}
+
+		return result, nil
+	}
+
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return FilterResult{}, fmt.Errorf("tag %s (%s) has to be true or false", config.TagEnabled, value)
+	}
+
+	result.Reason = fmt.Sprintf("nodegroup is opted in with the '%s=true' tag", config.TagEnabled)
+	if !enabled {
+		result.Verdict, result.Reason = VerdictSkip, fmt.Sprintf("nodegroup is opted out with the '%s=false' tag", config.TagEnabled)
+	}
+
+	return result, nil
+}
+
+func (tagFilter) Name() string {
+	return CheckTag
+}
+
+func (f tagFilter) Filter(candidate *Candidate, ctx context.Context) (FilterResult, error) {
+	hasTag, err := aws.HasNodegroupTag(f.tag, candidate.Description.Tags, ctx)
+	if err != nil {
+		return FilterResult{}, err
+	}
+
+	result := FilterResult{Verdict: VerdictKeep, Reason: fmt.Sprintf("nodegroup has the '%s' tag", f.tag), Inputs: map[string]string{"tag": f.tag}}
+	if !hasTag {
+		result.Verdict, result.Reason = VerdictSkip, fmt.Sprintf("nodegroup does not have the '%s' tag", f.tag)
+	}
+
+	return result, nil
+}
+
+func (pinnedReleaseFilter) Name() string {
+	return CheckPinnedRelease
+}
+
+func (pinnedReleaseFilter) Filter(candidate *Candidate, ctx context.Context) (FilterResult, error) {
+	release, isPinned, err := candidate.PinnedRelease()
+	if err != nil {
+		return FilterResult{}, err
+	}
+	if !isPinned {
+		return FilterResult{Verdict: VerdictKeep, Reason: "release is not pinned"}, nil
+	}
+
+	isMissing, err := isPinnedReleaseMissing(candidate.Nodegroup, eks.DescribeNodegroupOutput{Nodegroup: candidate.Description}, release, ctx)
+	if err != nil {
+		return FilterResult{}, err
+	}
+
+	result := FilterResult{
+		Verdict: VerdictKeep,
+		Reason:  "pinned release is not in use",
+		Inputs:  map[string]string{"release": release, "currentRelease": *candidate.Description.ReleaseVersion},
+	}
+	if !isMissing {
+		result.Verdict, result.Reason = VerdictSkip, "pinned release is already in use"
+	}
+
+	return result, nil
+}
+
+func (sameVersionFilter) Name() string {
+	return CheckSameVersion
+}
+
+func (sameVersionFilter) Filter(candidate *Candidate, ctx context.Context) (FilterResult, error) {
+	_, isPinned, err := candidate.PinnedRelease()
+	if err != nil {
+		return FilterResult{}, err
+	}
+	if isPinned {
+		return FilterResult{Verdict: VerdictKeep, Reason: "pinned release is not compared with the latest ami"}, nil
+	}
+
+	latestAmi, err := candidate.LatestAmi(ctx)
+	if err != nil {
+		return FilterResult{}, err
+	}
+
+	nodegroup := candidate.Description
+	isTheSameAmiVersion, err := aws.IsTheSameAmiVersion(candidate.Nodegroup, string(nodegroup.AmiType), *nodegroup.ReleaseVersion, latestAmi, ctx)
+	if err != nil {
+		return FilterResult{}, err
+	}
+
+	result := FilterResult{
+		Verdict: VerdictKeep,
+		Reason:  "newer ami is available",
+		Inputs:  map[string]string{"currentRelease": *nodegroup.ReleaseVersion, "latestRelease": latestAmi.Release, "latestAmi": latestAmi.ImageID},
+	}
+	if isTheSameAmiVersion {
+		result.Verdict, result.Reason = VerdictSkip, "the newest ami is already in use"
+	}
+
+	return result, nil
+}
+
+func (ageFilter) Name() string {
+	return CheckAge
+}
+
+func (ageFilter) Filter(candidate *Candidate, ctx context.Context) (FilterResult, error) {
+	policy, err := candidate.resolvedPolicy()
+	if err != nil {
+		return FilterResult{}, err
+	}
+	skipNewerThanDays := policy.skipNewerThanDays
+	if policy.release != config.ReleaseLatest {
+		return FilterResult{Verdict: VerdictKeep, Reason: "pinned release skips the age check"}, nil
+	}
+	if skipNewerThanDays == 0 {
+		return FilterResult{Verdict: VerdictKeep, Reason: "no minimum ami age is set"}, nil
+	}
+
+	latestAmi, err := candidate.LatestAmi(ctx)
+	if err != nil {
+		return FilterResult{}, err
+	}
+
+	isOldEnough := aws.IsLastAmiOldEnough(skipNewerThanDays, candidate.Nodegroup, time.Now(), latestAmi, ctx)
+
+	result := FilterResult{
+		Verdict: VerdictKeep,
+		Reason:  fmt.Sprintf("latest ami was published more than %d days ago", skipNewerThanDays),
+		Inputs:  map[string]string{"skipNewerThanDays": strconv.FormatUint(uint64(skipNewerThanDays), 10), "published": latestAmi.PublishDate.UTC().Format(time.RFC3339)},
+	}
+	if !isOldEnough {
+		result.Verdict, result.Reason = VerdictDefer, fmt.Sprintf("latest ami was published less than %d days ago", skipNewerThanDays)
+	}
+
+	return result, nil
+}
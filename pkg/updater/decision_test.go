@@ -0,0 +1,247 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"testing"
+
+	awsLib "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	eksTypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/loomhq/eks-ng-ami-updater/pkg/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPipelineDecide(t *testing.T) {
+	t.Parallel()
+
+	nodegroup := aws.NodeGroup{Region: "eu-west-1", ClusterName: "cluster-1", NodegroupName: "ng-1"}
+	policy := nodegroupPolicy{release: "1.29.0-20240202"}
+
+	tests := []struct {
+		name            string
+		tag             string
+		optIn           bool
+		updateFirst     string
+		tags            map[string]string
+		labels          map[string]string
+		status          eksTypes.NodegroupStatus
+		version         string
+		release         string
+		filters         []Filter
+		expectedVerdict Verdict
+		expectedUpdate  bool
+		expectedFirst   bool
+		expectedChecks  []string
+		expectedRelease string
+		expectedError   string
+	}{
+		{name: "every filter keeps the nodegroup",
+			tag:             "env:production",
+			status:          eksTypes.NodegroupStatusActive,
+			release:         "1.29.0-20240117",
+			expectedVerdict: VerdictKeep,
+			expectedUpdate:  true,
+			expectedChecks:  []string{CheckStatus, CheckEnabled, CheckTag, CheckPinnedRelease, CheckSameVersion, CheckAge},
+		},
+		{name: "updating nodegroup is deferred",
+			status:          eksTypes.NodegroupStatusUpdating,
+			release:         "1.29.0-20240117",
+			expectedVerdict: VerdictDefer,
+			expectedChecks:  []string{CheckStatus},
+		},
+		{name: "tag does not match",
+			tag:             "env:staging",
+			status:          eksTypes.NodegroupStatusDegraded,
+			release:         "1.29.0-20240117",
+			expectedVerdict: VerdictSkip,
+			expectedChecks:  []string{CheckStatus, CheckEnabled, CheckTag},
+		},
+		{name: "pinned release is in use",
+			status:          eksTypes.NodegroupStatusActive,
+			release:         "1.29.0-20240202",
+			expectedVerdict: VerdictSkip,
+			expectedChecks:  []string{CheckStatus, CheckEnabled, CheckPinnedRelease},
+		},
+		{name: "custom filter defers the nodegroup",
+			status:          eksTypes.NodegroupStatusActive,
+			release:         "1.29.0-20240117",
+			filters:         []Filter{testFilter{name: "maintenance-window", verdict: VerdictDefer}},
+			expectedVerdict: VerdictDefer,
+			expectedChecks:  []string{CheckStatus, CheckEnabled, CheckPinnedRelease, CheckSameVersion, CheckAge, "maintenance-window"},
+		},
+		{name: "custom filter returns an unknown verdict",
+			status:          eksTypes.NodegroupStatusActive,
+			release:         "1.29.0-20240117",
+			filters:         []Filter{testFilter{name: "broken", verdict: "maybe"}},
+			expectedVerdict: VerdictKeep,
+			expectedChecks:  []string{CheckStatus, CheckEnabled, CheckPinnedRelease, CheckSameVersion, CheckAge},
+			expectedError:   "filter (broken) returned an unknown verdict (maybe)",
+		},
+		{name: "pinned release is for another kubernetes version",
+			status:          eksTypes.NodegroupStatusActive,
+			version:         "1.30",
+			release:         "1.30.0-20240117",
+			expectedVerdict: VerdictKeep,
+			expectedChecks:  []string{CheckStatus, CheckEnabled},
+			expectedError:   "pinned release (1.29.0-20240202) is not for the nodegroup's kubernetes version (1.30)",
+		},
+		{name: "nodegroup is opted out",
+			tags:            map[string]string{"eks-ng-ami-updater/enabled": "false"},
+			status:          eksTypes.NodegroupStatusActive,
+			release:         "1.29.0-20240117",
+			expectedVerdict: VerdictSkip,
+			expectedChecks:  []string{CheckStatus, CheckEnabled},
+		},
+		{name: "nodegroup is not opted in",
+			optIn:           true,
+			status:          eksTypes.NodegroupStatusActive,
+			release:         "1.29.0-20240117",
+			expectedVerdict: VerdictSkip,
+			expectedChecks:  []string{CheckStatus, CheckEnabled},
+		},
+		{name: "nodegroup is opted in",
+			optIn:           true,
+			tags:            map[string]string{"eks-ng-ami-updater/enabled": "true"},
+			status:          eksTypes.NodegroupStatusActive,
+			release:         "1.29.0-20240117",
+			expectedVerdict: VerdictKeep,
+			expectedUpdate:  true,
+			expectedChecks:  []string{CheckStatus, CheckEnabled, CheckPinnedRelease, CheckSameVersion, CheckAge},
+		},
+		{name: "invalid enabled tag",
+			tags:            map[string]string{"eks-ng-ami-updater/enabled": "maybe"},
+			status:          eksTypes.NodegroupStatusActive,
+			release:         "1.29.0-20240117",
+			expectedVerdict: VerdictKeep,
+			expectedChecks:  []string{CheckStatus},
+			expectedError:   "tag eks-ng-ami-updater/enabled (maybe) has to be true or false",
+		},
+		{name: "release tag overrides the config policy",
+			tags:            map[string]string{"eks-ng-ami-updater/release": "1.29.0-20240117"},
+			status:          eksTypes.NodegroupStatusActive,
+			release:         "1.29.0-20240117",
+			expectedVerdict: VerdictSkip,
+			expectedChecks:  []string{CheckStatus, CheckEnabled, CheckPinnedRelease},
+			expectedRelease: "1.29.0-20240117",
+		},
+		{name: "invalid override tag",
+			tags:            map[string]string{"eks-ng-ami-updater/force": "yes"},
+			status:          eksTypes.NodegroupStatusActive,
+			release:         "1.29.0-20240117",
+			expectedVerdict: VerdictKeep,
+			expectedChecks:  []string{CheckStatus, CheckEnabled},
+			expectedError:   "tag eks-ng-ami-updater/force (yes) has to be true or false",
+		},
+		{name: "nodegroup matching the update first selector is updated first",
+			updateFirst:     "role=system",
+			labels:          map[string]string{"role": "system"},
+			status:          eksTypes.NodegroupStatusActive,
+			release:         "1.29.0-20240117",
+			expectedVerdict: VerdictKeep,
+			expectedUpdate:  true,
+			expectedFirst:   true,
+			expectedChecks:  []string{CheckStatus, CheckEnabled, CheckPinnedRelease, CheckSameVersion, CheckAge},
+		},
+		{name: "nodegroup not matching the update first selector is updated later",
+			updateFirst:     "role=system",
+			labels:          map[string]string{"role": "batch"},
+			status:          eksTypes.NodegroupStatusActive,
+			release:         "1.29.0-20240117",
+			expectedVerdict: VerdictKeep,
+			expectedUpdate:  true,
+			expectedChecks:  []string{CheckStatus, CheckEnabled, CheckPinnedRelease, CheckSameVersion, CheckAge},
+		},
+		{name: "opted out nodegroup with an invalid override tag is skipped",
+			tags:            map[string]string{"eks-ng-ami-updater/enabled": "false", "eks-ng-ami-updater/skip-newer-than-days": "abc"},
+			status:          eksTypes.NodegroupStatusActive,
+			release:         "1.29.0-20240117",
+			expectedVerdict: VerdictSkip,
+			expectedChecks:  []string{CheckStatus, CheckEnabled},
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		version := "1.29"
+		if test.version != "" {
+			version = test.version
+		}
+		described := describedNodegroup{nodegroup: nodegroup, policy: policy, description: eks.DescribeNodegroupOutput{Nodegroup: &eksTypes.Nodegroup{
+			AmiType:        eksTypes.AMITypesAl2X8664,
+			Version:        awsLib.String(version),
+			ReleaseVersion: awsLib.String(test.release),
+			Status:         test.status,
+			Tags:           map[string]string{"env": "production"},
+			Labels:         test.labels,
+		}}}
+		maps.Copy(described.description.Nodegroup.Tags, test.tags)
+		pipeline, err := NewPipeline(Options{Tag: test.tag, OptIn: test.optIn, UpdateFirstSelector: test.updateFirst})
+		assert.NoError(t, err)
+		for _, filter := range test.filters {
+			pipeline.Register(filter)
+		}
+
+		decision := pipeline.decide(described, nil, context.Background())
+
+		var checks []string
+		for _, check := range decision.Checks {
+			checks = append(checks, check.Name)
+		}
+		assert.Equal(t, test.expectedChecks, checks)
+		assert.Equal(t, test.expectedVerdict, decision.Verdict)
+		assert.Equal(t, test.expectedUpdate, decision.Update != nil)
+		assert.Equal(t, test.expectedFirst, decision.Update != nil && decision.Update.First)
+		if test.expectedRelease != "" {
+			assert.Equal(t, test.expectedRelease, decision.policy.release)
+			assert.Equal(t, map[string]string{"eks-ng-ami-updater/release": test.expectedRelease}, decision.TagOverrides)
+		}
+		if test.expectedError != "" {
+			assert.ErrorContains(t, decision.Err, test.expectedError)
+		} else {
+			assert.NoError(t, decision.Err)
+		}
+	}
+}
+
+func TestPrintDecisions(t *testing.T) {
+	t.Parallel()
+
+	decisions := []Decision{
+		{
+			Nodegroup: aws.NodeGroup{AccountID: "111111111111", Region: "eu-west-1", ClusterName: "cluster-1", NodegroupName: "ng-1"},
+			Checks: []Check{
+				{Name: CheckStatus, Inputs: map[string]string{"status": "ACTIVE"}, Verdict: VerdictKeep, Reason: "nodegroup status ACTIVE allows an ami update"},
+				{Name: CheckSameVersion, Inputs: map[string]string{"currentRelease": "1.29.0-20240117", "latestRelease": "v20240202"}, Verdict: VerdictKeep, Reason: "newer ami is available"},
+			},
+			Verdict:      VerdictKeep,
+			Update:       &Update{CurrentRelease: "1.29.0-20240117", TargetRelease: "v20240202"},
+			TagOverrides: map[string]string{"eks-ng-ami-updater/skip-newer-than-days": "7", "eks-ng-ami-updater/force": "false"},
+			policy:       nodegroupPolicy{release: "latest", skipNewerThanDays: 7, concurrency: 2, blocks: []string{"defaults", "tags"}},
+		},
+		{
+			Nodegroup: aws.NodeGroup{Region: "eu-west-1", ClusterName: "cluster-1", NodegroupName: "ng-2"},
+			Verdict:   VerdictKeep,
+			Err:       errors.New("nodegroup's ami type (CUSTOM) is not recognize"),
+			policy:    nodegroupPolicy{release: "latest"},
+		},
+	}
+
+	var output bytes.Buffer
+	err := PrintDecisions(&output, decisions)
+	assert.NoError(t, err)
+
+	lines := strings.Split(output.String(), "\n")
+	assert.Equal(t, "111111111111:eu-west-1:cluster-1:ng-1: update from 1.29.0-20240117 to v20240202", lines[0])
+	assert.Equal(t, "  policy: release latest, skip newer than 7 days, force false, concurrency 2 (config: defaults, tags)", lines[1])
+	assert.Equal(t, "  tag overrides: eks-ng-ami-updater/force=false eks-ng-ami-updater/skip-newer-than-days=7", lines[2])
+	assert.Equal(t, []string{"CHECK", "VERDICT", "REASON", "INPUTS"}, strings.Fields(lines[3]))
+	assert.Equal(t, "status keep nodegroup status ACTIVE allows an ami update status=ACTIVE", strings.Join(strings.Fields(lines[4]), " "))
+	assert.Equal(t, "same-version keep newer ami is available currentRelease=1.29.0-20240117 latestRelease=v20240202", strings.Join(strings.Fields(lines[5]), " "))
+	assert.Equal(t, "eu-west-1:cluster-1:ng-2: error (nodegroup's ami type (CUSTOM) is not recognize)", lines[7])
+	assert.Equal(t, "  policy: release latest, skip newer than 0 days, force false, concurrency unlimited", lines[8])
+}
@@ -0,0 +1,138 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/loomhq/eks-ng-ami-updater/pkg/aws"
+	"github.com/rs/zerolog/log"
+)
+
+type Phase string
+
+const (
+	PhaseDiscovery Phase = "discovery"
+	PhaseDecision  Phase = "decision"
+	PhaseUpdate    Phase = "update"
+	PhaseWait      Phase = "wait"
+)
+
+type NodegroupError struct {
+	Account   string
+	Region    string
+	Cluster   string
+	Nodegroup string
+	Phase     Phase
+	Code      string
+	Err       error
+}
+
+type MultiError struct {
+	Errors []*NodegroupError
+}
+
+func NewNodegroupError(nodegroup aws.NodeGroup, phase Phase, err error) *NodegroupError {
+	return &NodegroupError{
+		Account:   nodegroup.AccountID,
+		Region:    nodegroup.Region,
+		Cluster:   nodegroup.ClusterName,
+		Nodegroup: nodegroup.NodegroupName,
+		Phase:     phase,
+		Code:      aws.ErrorCode(err),
+		Err:       err,
+	}
+}
+
+func (e *NodegroupError) Error() string {
+	return fmt.Sprintf("account: %s, region: %s, cluster: %s, nodegroup: %s, phase: %s, code: %s : %v", e.Account, e.Region, e.Cluster, e.Nodegroup, e.Phase, e.Code, e.Err)
+}
+
+func (e *NodegroupError) Unwrap() error {
+	return e.Err
+}
+
+func (m *MultiError) Error() string {
+	messages := make([]string, 0, len(m.Errors))
+	for _, e := range m.Errors {
+		messages = append(messages, e.Error())
+	}
+
+	return fmt.Sprintf("%d nodegroup operation(s) failed: %s", len(m.Errors), strings.Join(messages, "; "))
+}
+
+func (m *MultiError) Sort() {
+	sort.SliceStable(m.Errors, func(i, j int) bool {
+		a, b := m.Errors[i], m.Errors[j]
+		if a.Account != b.Account {
+			return a.Account < b.Account
+		}
+		if a.Region != b.Region {
+			return a.Region < b.Region
+		}
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+
+		return a.Nodegroup < b.Nodegroup
+	})
+}
+
+func newMultiError(errors []*NodegroupError) error {
+	if len(errors) == 0 {
+		return nil
+	}
+
+	multiError := &MultiError{Errors: errors}
+	multiError.Sort()
+
+	return multiError
+}
+
+func (m *MultiError) LogSummary(ctx context.Context) {
+	logWithContext := log.Ctx(ctx).With().Str("function", "LogSummary").Logger()
+
+	for _, e := range m.Errors {
+		logWithContext.Error().Str("account", e.Account).Str("region", e.Region).Str("cluster", e.Cluster).Str("nodegroup", e.Nodegroup).
+			Str("phase", string(e.Phase)).Str("code", e.Code).Err(e.Err).Msg("nodegroup failed")
+	}
+
+	logWithContext.Error().Int("failures", len(m.Errors)).Msg("run finished with failures")
+}
+
+type SkippedRegion struct {
+	AccountID string
+	Region    string
+	Code      string
+	Err       error
+}
+
+type findings struct {
+	strict         bool
+	errors         []*NodegroupError
+	skippedRegions []SkippedRegion
+}
+
+func (f *findings) record(nodegroup aws.NodeGroup, phase Phase, err error, ctx context.Context) error {
+	logWithContext := log.Ctx(ctx).With().Str("function", "record").Logger()
+
+	nodegroupError := NewNodegroupError(nodegroup, phase, err)
+	if f.strict {
+		return nodegroupError
+	}
+
+	logWithContext.Warn().Str("account", nodegroup.AccountID).Str("region", nodegroup.Region).Str("cluster", nodegroup.ClusterName).Str("nodegroup", nodegroup.NodegroupName).
+		Str("phase", string(phase)).Str("code", nodegroupError.Code).Err(err).Msg("skipping after error (use '--strict=true' to stop the run instead)")
+	f.errors = append(f.errors, nodegroupError)
+
+	return nil
+}
+
+func (f *findings) skipRegion(accountID, region string, err error, ctx context.Context) {
+	logWithContext := log.Ctx(ctx).With().Str("function", "skipRegion").Logger()
+
+	skipped := SkippedRegion{AccountID: accountID, Region: region, Code: aws.ErrorCode(err), Err: err}
+	logWithContext.Warn().Str("account", accountID).Str("region", region).Str("code", skipped.Code).Err(err).Msg("skip unavailable region (eks calls are denied or the region is not enabled)")
+	f.skippedRegions = append(f.skippedRegions, skipped)
+}
@@ -0,0 +1,116 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/smithy-go"
+	"github.com/loomhq/eks-ng-ami-updater/pkg/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewNodegroupError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		nodegroup     aws.NodeGroup
+		phase         Phase
+		err           error
+		expectedCode  string
+		expectedValue string
+	}{
+		{name: "aws error during update",
+			nodegroup:     aws.NodeGroup{AccountID: "123456789012", Region: "eu-west-1", ClusterName: "cluster-1", NodegroupName: "ng-1"},
+			phase:         PhaseUpdate,
+			err:           &smithy.GenericAPIError{Code: "ResourceInUseException", Message: "update already in progress"},
+			expectedCode:  "ResourceInUseException",
+			expectedValue: "account: 123456789012, region: eu-west-1, cluster: cluster-1, nodegroup: ng-1, phase: update, code: ResourceInUseException : api error ResourceInUseException: update already in progress",
+		},
+		{name: "non aws error during decision",
+			nodegroup:     aws.NodeGroup{AccountID: "123456789012", Region: "eu-west-1", ClusterName: "cluster-1", NodegroupName: "ng-1"},
+			phase:         PhaseDecision,
+			err:           errors.New("nodegroup's ami type (SOMETHINGNEW_x86_64) is not recognize"),
+			expectedCode:  "",
+			expectedValue: "account: 123456789012, region: eu-west-1, cluster: cluster-1, nodegroup: ng-1, phase: decision, code:  : nodegroup's ami type (SOMETHINGNEW_x86_64) is not recognize",
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+
+		output := NewNodegroupError(test.nodegroup, test.phase, test.err)
+
+		assert.Equal(t, test.expectedCode, output.Code)
+		assert.Equal(t, test.expectedValue, output.Error())
+		assert.ErrorIs(t, output, test.err)
+	}
+}
+
+func TestMultiErrorSort(t *testing.T) {
+	t.Parallel()
+
+	multiError := MultiError{Errors: []*NodegroupError{
+		{Account: "222222222222", Region: "eu-west-1", Cluster: "cluster-1", Nodegroup: "ng-1", Phase: PhaseWait},
+		{Region: "us-west-2", Cluster: "cluster-1", Nodegroup: "ng-1", Phase: PhaseWait},
+		{Region: "eu-west-1", Cluster: "cluster-2", Nodegroup: "ng-1", Phase: PhaseUpdate},
+		{Region: "eu-west-1", Cluster: "cluster-1", Nodegroup: "ng-2", Phase: PhaseUpdate},
+		{Region: "eu-west-1", Cluster: "cluster-1", Nodegroup: "ng-1", Phase: PhaseWait},
+	}}
+
+	multiError.Sort()
+
+	var output []string
+	for _, e := range multiError.Errors {
+		output = append(output, e.Account+":"+e.Region+":"+e.Cluster+":"+e.Nodegroup)
+	}
+
+	assert.Equal(t, []string{":eu-west-1:cluster-1:ng-1", ":eu-west-1:cluster-1:ng-2", ":eu-west-1:cluster-2:ng-1", ":us-west-2:cluster-1:ng-1", "222222222222:eu-west-1:cluster-1:ng-1"}, output)
+}
+
+func TestFindingsRecord(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name             string
+		strict           bool
+		expectedError    bool
+		expectedFindings int
+	}{
+		{name: "strict mode stops on the first error",
+			strict:           true,
+			expectedError:    true,
+			expectedFindings: 0,
+		},
+		{name: "default mode records the finding and continues",
+			strict:           false,
+			expectedError:    false,
+			expectedFindings: 1,
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		discoveryFindings := findings{strict: test.strict}
+		nodegroup := aws.NodeGroup{Region: "eu-west-1", ClusterName: "cluster-1", NodegroupName: "ng-1"}
+
+		err := discoveryFindings.record(nodegroup, PhaseDiscovery, &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized"}, context.Background())
+
+		assert.Equal(t, test.expectedError, err != nil)
+		assert.Len(t, discoveryFindings.errors, test.expectedFindings)
+	}
+}
+
+func TestFindingsSkipRegion(t *testing.T) {
+	t.Parallel()
+
+	discoveryFindings := findings{strict: true}
+
+	discoveryFindings.skipRegion("111111111111", "me-south-1", &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "explicit deny in a service control policy"}, context.Background())
+
+	assert.Empty(t, discoveryFindings.errors)
+	assert.Equal(t, []SkippedRegion{{AccountID: "111111111111", Region: "me-south-1", Code: "AccessDeniedException",
+		Err: &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "explicit deny in a service control policy"}}}, discoveryFindings.skippedRegions)
+}
@@ -0,0 +1,105 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+)
+
+type ExpressionFilter struct {
+	expression string
+	program    cel.Program
+}
+
+func NewExpressionFilter(expression string) (*ExpressionFilter, error) {
+	env, err := cel.NewEnv(cel.Variable("ng", cel.MapType(cel.StringType, cel.DynType)))
+	if err != nil {
+		return nil, fmt.Errorf("error creating filter expression environment: %w", err)
+	}
+
+	ast, issues := env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("filter expression (%s) is invalid: %w", expression, issues.Err())
+	}
+	if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+		return nil, fmt.Errorf("filter expression (%s) has to return a bool, not %s", expression, ast.OutputType())
+	}
+
+	program, err := env.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("filter expression (%s) is invalid: %w", expression, err)
+	}
+
+	return &ExpressionFilter{expression: expression, program: program}, nil
+}
+
+func (*ExpressionFilter) Name() string {
+	return CheckFilter
+}
+
+func (f *ExpressionFilter) Filter(candidate *Candidate, ctx context.Context) (FilterResult, error) {
+	result := FilterResult{Verdict: VerdictKeep, Reason: "nodegroup matches the filter expression", Inputs: map[string]string{"filter": f.expression}}
+
+	// an expression which can not be evaluated, eg. because of a missing tag, does not match the nodegroup
+	output, _, err := f.program.ContextEval(ctx, map[string]any{"ng": nodegroupObject(candidate)})
+	if err != nil {
+		result.Verdict, result.Reason = VerdictSkip, fmt.Sprintf("filter expression can not be evaluated for the nodegroup (%v)", err)
+
+		return result, nil
+	}
+
+	matches, ok := output.Value().(bool)
+	if !ok {
+		return FilterResult{}, fmt.Errorf("filter expression (%s) returned %v instead of a bool", f.expression, output.Value())
+	}
+	if !matches {
+		result.Verdict, result.Reason = VerdictSkip, "nodegroup does not match the filter expression"
+	}
+
+	return result, nil
+}
+
+func nodegroupObject(candidate *Candidate) map[string]any {
+	nodegroup := candidate.Description
+
+	scalingConfig := map[string]any{}
+	if nodegroup.ScalingConfig != nil {
+		scalingConfig["minSize"] = int64(valueOf(nodegroup.ScalingConfig.MinSize))
+		scalingConfig["maxSize"] = int64(valueOf(nodegroup.ScalingConfig.MaxSize))
+		scalingConfig["desiredSize"] = int64(valueOf(nodegroup.ScalingConfig.DesiredSize))
+	}
+
+	return map[string]any{
+		"account":        candidate.Nodegroup.AccountID,
+		"region":         candidate.Nodegroup.Region,
+		"cluster":        candidate.Nodegroup.ClusterName,
+		"name":           candidate.Nodegroup.NodegroupName,
+		"tags":           orEmpty(nodegroup.Tags),
+		"labels":         orEmpty(nodegroup.Labels),
+		"amiType":        string(nodegroup.AmiType),
+		"version":        valueOf(nodegroup.Version),
+		"releaseVersion": valueOf(nodegroup.ReleaseVersion),
+		"capacityType":   string(nodegroup.CapacityType),
+		"instanceTypes":  append([]string{}, nodegroup.InstanceTypes...),
+		"status":         string(nodegroup.Status),
+		"scalingConfig":  scalingConfig,
+	}
+}
+
+func orEmpty(values map[string]string) map[string]string {
+	if values == nil {
+		return map[string]string{}
+	}
+
+	return values
+}
+
+func valueOf[T any](pointer *T) T {
+	var value T
+	if pointer != nil {
+		value = *pointer
+	}
+
+	return value
+}
@@ -0,0 +1,106 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	awsLib "github.com/aws/aws-sdk-go-v2/aws"
+	eksTypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/loomhq/eks-ng-ami-updater/pkg/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewExpressionFilter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		expression    string
+		expectedError string
+	}{
+		{name: "valid expression",
+			expression: `ng.tags["env"] in ["prod", "preprod"] && ng.capacityType == "ON_DEMAND" && !ng.amiType.startsWith("WINDOWS")`,
+		},
+		{name: "syntax error",
+			expression:    `ng.tags["env"] ==`,
+			expectedError: "is invalid",
+		},
+		{name: "undeclared variable",
+			expression:    `nodegroup.name == "ng-1"`,
+			expectedError: "undeclared reference to 'nodegroup'",
+		},
+		{name: "expression does not return a bool",
+			expression:    `"ng-1"`,
+			expectedError: "has to return a bool, not string",
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+
+		_, err := NewExpressionFilter(test.expression)
+
+		if test.expectedError != "" {
+			assert.ErrorContains(t, err, test.expectedError)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+}
+
+func TestExpressionFilter(t *testing.T) {
+	t.Parallel()
+
+	candidate := &Candidate{
+		Nodegroup: aws.NodeGroup{AccountID: "111111111111", Region: "eu-west-1", ClusterName: "cluster-1", NodegroupName: "ng-1"},
+		Description: &eksTypes.Nodegroup{
+			AmiType:       eksTypes.AMITypesAl2X8664,
+			Version:       awsLib.String("1.29"),
+			CapacityType:  eksTypes.CapacityTypesOnDemand,
+			InstanceTypes: []string{"m5.large", "m5a.large"},
+			Tags:          map[string]string{"env": "prod"},
+			ScalingConfig: &eksTypes.NodegroupScalingConfig{MinSize: awsLib.Int32(1), MaxSize: awsLib.Int32(5), DesiredSize: awsLib.Int32(3)},
+		},
+	}
+
+	tests := []struct {
+		name            string
+		expression      string
+		expectedVerdict Verdict
+		expectedReason  string
+	}{
+		{name: "nodegroup matches",
+			expression:      `ng.tags["env"] in ["prod", "preprod"] && ng.capacityType == "ON_DEMAND" && !ng.amiType.startsWith("WINDOWS")`,
+			expectedVerdict: VerdictKeep,
+			expectedReason:  "nodegroup matches the filter expression",
+		},
+		{name: "nodegroup does not match",
+			expression:      `ng.region == "us-west-2" || ng.scalingConfig.desiredSize > 3`,
+			expectedVerdict: VerdictSkip,
+			expectedReason:  "nodegroup does not match the filter expression",
+		},
+		{name: "instance types and labels",
+			expression:      `"m5.large" in ng.instanceTypes && !("role" in ng.labels) && ng.version == "1.29"`,
+			expectedVerdict: VerdictKeep,
+			expectedReason:  "nodegroup matches the filter expression",
+		},
+		{name: "missing tag does not match",
+			expression:      `ng.tags["team"] == "platform"`,
+			expectedVerdict: VerdictSkip,
+			expectedReason:  "filter expression can not be evaluated for the nodegroup (no such key: team)",
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		filter, err := NewExpressionFilter(test.expression)
+		assert.NoError(t, err)
+
+		result, err := filter.Filter(candidate, context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, test.expectedVerdict, result.Verdict)
+		assert.Equal(t, test.expectedReason, result.Reason)
+	}
+}
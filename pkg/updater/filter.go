@@ -0,0 +1,223 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	eksTypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/loomhq/eks-ng-ami-updater/pkg/aws"
+	"github.com/loomhq/eks-ng-ami-updater/pkg/config"
+	"github.com/loomhq/eks-ng-ami-updater/pkg/selector"
+	"github.com/rs/zerolog/log"
+)
+
+type Verdict string
+
+const (
+	VerdictKeep  Verdict = "keep"
+	VerdictSkip  Verdict = "skip"
+	VerdictDefer Verdict = "defer"
+)
+
+type FilterResult struct {
+	Verdict Verdict
+	Reason  string
+	Inputs  map[string]string
+}
+
+type Filter interface {
+	Name() string
+	Filter(candidate *Candidate, ctx context.Context) (FilterResult, error)
+}
+
+type Candidate struct {
+	Nodegroup   aws.NodeGroup
+	Description *eksTypes.Nodegroup
+	policy      nodegroupPolicy
+	amiKey      aws.AmiKey
+	resolver    *aws.AmiResolver
+	clients     aws.Clients
+	overrides   map[string]string
+	overrideErr error
+	overridden  bool
+}
+
+type Pipeline struct {
+	filters     []Filter
+	updateFirst selector.Selector
+}
+
+func NewPipeline(options Options) (*Pipeline, error) {
+	pipeline := &Pipeline{}
+	pipeline.Register(statusFilter{})
+	pipeline.Register(enabledFilter{optIn: options.OptIn})
+	if options.Tag != "" {
+		pipeline.Register(tagFilter{tag: options.Tag})
+	}
+	for _, filter := range []selectorFilter{
+		{name: CheckTagSelector, subject: "tags", expression: options.TagSelector, values: nodegroupTags},
+		{name: CheckExcludeTagSelector, subject: "tags", expression: options.ExcludeTagSelector, exclude: true, values: nodegroupTags},
+		{name: CheckLabelSelector, subject: "labels", expression: options.LabelSelector, values: nodegroupLabels},
+		{name: CheckExcludeLabelSelector, subject: "labels", expression: options.ExcludeLabelSelector, exclude: true, values: nodegroupLabels},
+		{name: CheckTaintSelector, subject: "taints", expression: options.TaintSelector, values: nodegroupTaints},
+		{name: CheckExcludeTaintSelector, subject: "taints", expression: options.ExcludeTaintSelector, exclude: true, values: nodegroupTaints},
+	} {
+		if filter.expression == "" {
+			continue
+		}
+		err := filter.parse()
+		if err != nil {
+			return nil, err
+		}
+		pipeline.Register(filter)
+	}
+	if options.Filter != "" {
+		expressionFilter, err := NewExpressionFilter(options.Filter)
+		if err != nil {
+			return nil, err
+		}
+		pipeline.Register(expressionFilter)
+	}
+	if options.UpdateFirstSelector != "" {
+		updateFirst, err := selector.Parse(options.UpdateFirstSelector)
+		if err != nil {
+			return nil, fmt.Errorf("--update-first-selector: %w", err)
+		}
+		pipeline.updateFirst = updateFirst
+	}
+	pipeline.Register(pinnedReleaseFilter{})
+	pipeline.Register(sameVersionFilter{})
+	pipeline.Register(ageFilter{})
+
+	return pipeline, nil
+}
+
+func (p *Pipeline) Register(filter Filter) {
+	p.filters = append(p.filters, filter)
+}
+
+func (p *Pipeline) RegisterBefore(name string, filter Filter) error {
+	index := slices.IndexFunc(p.filters, func(registered Filter) bool { return registered.Name() == name })
+	if index < 0 {
+		return fmt.Errorf("filter (%s) is not registered", name)
+	}
+	p.filters = slices.Insert(p.filters, index, filter)
+
+	return nil
+}
+
+func (p *Pipeline) Filters() []string {
+	names := make([]string, 0, len(p.filters))
+	for _, filter := range p.filters {
+		names = append(names, filter.Name())
+	}
+
+	return names
+}
+
+func (p *Pipeline) Plan(options Options, policies *config.Config, clients aws.Clients, ctx context.Context) (*Plan, error) {
+	return newPlan(options, policies, p, clients, ctx)
+}
+
+func (p *Pipeline) decide(described describedNodegroup, resolver *aws.AmiResolver, ctx context.Context) Decision {
+	nodegroup := described.nodegroup
+	decision := Decision{Nodegroup: nodegroup, Verdict: VerdictKeep, policy: described.policy}
+	candidate := &Candidate{
+		Nodegroup:   nodegroup,
+		Description: described.description.Nodegroup,
+		policy:      described.policy,
+		amiKey:      amiKey(nodegroup, described.description),
+		resolver:    resolver,
+		clients:     described.clients,
+	}
+
+	logWithContext := log.Ctx(ctx).With().Str("function", "decide").Logger()
+
+	if len(described.policy.blocks) > 0 {
+		policy := described.policy
+		logWithContext.Debug().Str("region", nodegroup.Region).Str("cluster", nodegroup.ClusterName).Str("nodegroup", nodegroup.NodegroupName).Strs("blocks", policy.blocks).
+			Uint("skipNewerThanDays", policy.skipNewerThanDays).Str("release", policy.release).Bool("force", policy.force).Int("concurrency", policy.concurrency).Msg("config policy applies to nodegroup")
+	}
+
+	if p.runFilters(candidate, &decision, ctx) {
+		described.policy, decision.Err = candidate.resolvedPolicy()
+		if decision.Err == nil {
+			decision.Update, decision.Err = newUpdate(described, resolver, ctx)
+		}
+		if decision.Update != nil && p.updateFirst != nil {
+			_, hasMismatch := p.updateFirst.FirstMismatch(nodegroupLabels(candidate))
+			decision.Update.First = !hasMismatch
+		}
+	}
+	decision.TagOverrides, decision.policy = candidate.overrides, candidate.policy
+
+	return decision
+}
+
+func (p *Pipeline) runFilters(candidate *Candidate, decision *Decision, ctx context.Context) bool {
+	// the first filter which does not keep the nodegroup decides, later filters are not run
+	for _, filter := range p.filters {
+		result, err := filter.Filter(candidate, ctx)
+		if err == nil && !slices.Contains([]Verdict{VerdictKeep, VerdictSkip, VerdictDefer}, result.Verdict) {
+			err = fmt.Errorf("filter (%s) returned an unknown verdict (%s)", filter.Name(), result.Verdict)
+		}
+		if err != nil {
+			decision.Err = err
+
+			return false
+		}
+		decision.record(Check{Name: filter.Name(), Inputs: result.Inputs, Verdict: result.Verdict, Reason: result.Reason}, ctx)
+		if result.Verdict != VerdictKeep {
+			decision.Verdict = result.Verdict
+
+			return false
+		}
+	}
+
+	return true
+}
+
+func applyTagOverrides(policy nodegroupPolicy, tags map[string]string) (nodegroupPolicy, map[string]string, error) {
+	tagPolicy, overrides, err := config.TagPolicy(tags)
+	if err != nil {
+		return policy, nil, err
+	}
+	if len(overrides) == 0 {
+		return policy, nil, nil
+	}
+
+	// tags of the nodegroup win over the config file, like a last override block
+	if tagPolicy.SkipNewerThanDays != nil {
+		policy.skipNewerThanDays = *tagPolicy.SkipNewerThanDays
+	}
+	if tagPolicy.Release != "" {
+		policy.release = tagPolicy.Release
+	}
+	if tagPolicy.Force != nil {
+		policy.force = *tagPolicy.Force
+	}
+	policy.blocks = append(slices.Clone(policy.blocks), config.TagsScope)
+
+	return policy, overrides, nil
+}
+
+func (c *Candidate) LatestAmi(ctx context.Context) (aws.AmiRecord, error) {
+	return c.resolver.Resolve(c.amiKey, c.clients, ctx)
+}
+
+func (c *Candidate) PinnedRelease() (string, bool, error) {
+	policy, err := c.resolvedPolicy()
+
+	return policy.release, policy.release != config.ReleaseLatest, err
+}
+
+func (c *Candidate) resolvedPolicy() (nodegroupPolicy, error) {
+	// override tags are read when a filter needs the policy, so a nodegroup skipped before does not fail on them
+	if !c.overridden {
+		c.policy, c.overrides, c.overrideErr = applyTagOverrides(c.policy, c.Description.Tags)
+		c.overridden = true
+	}
+
+	return c.policy, c.overrideErr
+}
@@ -0,0 +1,86 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testFilter struct {
+	name    string
+	verdict Verdict
+}
+
+func (f testFilter) Name() string {
+	return f.name
+}
+
+func (f testFilter) Filter(candidate *Candidate, ctx context.Context) (FilterResult, error) {
+	return FilterResult{Verdict: f.verdict, Reason: "test filter"}, nil
+}
+
+func TestPipelineRegister(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		options       Options
+		before        string
+		expectedValue []string
+		expectedError string
+	}{
+		{name: "built-in filters without tag",
+			expectedValue: []string{CheckStatus, CheckEnabled, CheckPinnedRelease, CheckSameVersion, CheckAge, "custom"},
+		},
+		{name: "built-in filters with tag",
+			options:       Options{Tag: "env:production"},
+			expectedValue: []string{CheckStatus, CheckEnabled, CheckTag, CheckPinnedRelease, CheckSameVersion, CheckAge, "custom"},
+		},
+		{name: "built-in filters with tag and filter expression",
+			options:       Options{Tag: "env:production", Filter: `ng.capacityType == "SPOT"`},
+			expectedValue: []string{CheckStatus, CheckEnabled, CheckTag, CheckFilter, CheckPinnedRelease, CheckSameVersion, CheckAge, "custom"},
+		},
+		{name: "built-in filters with tag selectors",
+			options:       Options{TagSelector: "env in (prod,stage)", ExcludeTagSelector: "skip-ami-update"},
+			expectedValue: []string{CheckStatus, CheckEnabled, CheckTagSelector, CheckExcludeTagSelector, CheckPinnedRelease, CheckSameVersion, CheckAge, "custom"},
+		},
+		{name: "built-in filters with label and taint selectors",
+			options:       Options{TagSelector: "team", LabelSelector: "role=system", ExcludeTaintSelector: "dedicated=gpu:NoSchedule"},
+			expectedValue: []string{CheckStatus, CheckEnabled, CheckTagSelector, CheckLabelSelector, CheckExcludeTaintSelector, CheckPinnedRelease, CheckSameVersion, CheckAge, "custom"},
+		},
+		{name: "custom filter before a built-in one",
+			before:        CheckSameVersion,
+			expectedValue: []string{CheckStatus, CheckEnabled, CheckPinnedRelease, "custom", CheckSameVersion, CheckAge},
+		},
+		{name: "unknown filter",
+			before:        "missing",
+			expectedValue: []string{CheckStatus, CheckEnabled, CheckPinnedRelease, CheckSameVersion, CheckAge},
+			expectedError: "filter (missing) is not registered",
+		},
+	}
+
+	_, err := NewPipeline(Options{ExcludeTagSelector: "env in (prod"})
+	assert.ErrorContains(t, err, "--exclude-tag-selector: selector (env in (prod) has an unclosed '('")
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		pipeline, err := NewPipeline(test.options)
+		assert.NoError(t, err)
+		filter := testFilter{name: "custom", verdict: VerdictKeep}
+
+		if test.before != "" {
+			err = pipeline.RegisterBefore(test.before, filter)
+		} else {
+			pipeline.Register(filter)
+		}
+
+		assert.Equal(t, test.expectedValue, pipeline.Filters())
+		if test.expectedError != "" {
+			assert.ErrorContains(t, err, test.expectedError)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+}
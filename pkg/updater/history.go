@@ -0,0 +1,123 @@
+package updater
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const historyFormatVersion = 1
+
+type UpdateResult string
+
+const (
+	UpdateResultUpdated UpdateResult = "updated"
+	UpdateResultFailed  UpdateResult = "failed"
+	UpdateResultDryrun  UpdateResult = "dryrun"
+)
+
+type RunRecord struct {
+	FormatVersion int            `json:"formatVersion"`
+	Command       string         `json:"command"`
+	StartedAt     time.Time      `json:"startedAt"`
+	FinishedAt    time.Time      `json:"finishedAt"`
+	Updates       []UpdateRecord `json:"updates"`
+	Failures      int            `json:"failures"`
+	// account:region of the regions skipped because eks calls are denied or the region is not enabled
+	SkippedRegions []string `json:"skippedRegions,omitempty"`
+}
+
+type UpdateRecord struct {
+	Account   string       `json:"account"`
+	Region    string       `json:"region"`
+	Cluster   string       `json:"cluster"`
+	Nodegroup string       `json:"nodegroup"`
+	Release   string       `json:"release"`
+	Result    UpdateResult `json:"result"`
+	Phase     Phase        `json:"phase,omitempty"`
+	Error     string       `json:"error,omitempty"`
+}
+
+func newUpdateRecord(update Update, dryrun bool, phase Phase, err error) UpdateRecord {
+	record := UpdateRecord{
+		Account:   update.Nodegroup.AccountID,
+		Region:    update.Nodegroup.Region,
+		Cluster:   update.Nodegroup.ClusterName,
+		Nodegroup: update.Nodegroup.NodegroupName,
+		Release:   update.ReleaseVersion,
+		Result:    UpdateResultUpdated,
+	}
+	// without a release version eks is asked for the latest ami, eg. for windows nodegroups
+	if record.Release == "" {
+		record.Release = update.policy.release
+	}
+	if dryrun {
+		record.Result = UpdateResultDryrun
+	}
+	if err != nil {
+		record.Result, record.Phase, record.Error = UpdateResultFailed, phase, err.Error()
+	}
+
+	return record
+}
+
+func AppendHistory(path string, record RunRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("error encoding run record: %w", err)
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("error opening history file %s: %w", path, err)
+	}
+
+	_, err = file.Write(append(data, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error writing history file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func LoadHistory(path string, limit int) ([]RunRecord, error) {
+	var records []RunRecord
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading history file %s: %w", path, err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for line := 1; scanner.Scan(); line++ {
+		var record RunRecord
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		err = json.Unmarshal(scanner.Bytes(), &record)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing line %d of history file %s: %w", line, path, err)
+		}
+		if record.FormatVersion != historyFormatVersion {
+			continue
+		}
+		records = append(records, record)
+	}
+
+	if limit > 0 && len(records) > limit {
+		records = records[len(records)-limit:]
+	}
+
+	return records, nil
+}
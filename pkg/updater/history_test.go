@@ -0,0 +1,139 @@
+package updater
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/loomhq/eks-ng-ami-updater/pkg/aws"
+	"github.com/loomhq/eks-ng-ami-updater/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory(t *testing.T) {
+	t.Parallel()
+
+	startedAt := time.Date(2024, 2, 4, 7, 30, 0, 0, time.UTC)
+	record := func(i int) RunRecord {
+		return RunRecord{
+			FormatVersion: historyFormatVersion,
+			Command:       "apply",
+			StartedAt:     startedAt.Add(time.Duration(i) * time.Hour),
+			FinishedAt:    startedAt.Add(time.Duration(i)*time.Hour + 10*time.Minute),
+			Updates:       []UpdateRecord{{Account: "111111111111", Region: "eu-west-1", Cluster: "cluster-1", Nodegroup: fmt.Sprintf("ng-%d", i), Release: "1.29.0-20240202", Result: UpdateResultUpdated}},
+		}
+	}
+
+	tests := []struct {
+		name          string
+		content       string
+		records       []RunRecord
+		limit         int
+		expectedValue []RunRecord
+		expectedError bool
+	}{
+		{name: "missing history file",
+			expectedValue: nil,
+		},
+		{name: "all records are loaded",
+			records:       []RunRecord{record(1), record(2)},
+			expectedValue: []RunRecord{record(1), record(2)},
+		},
+		{name: "only the latest records are loaded",
+			records:       []RunRecord{record(1), record(2), record(3)},
+			limit:         2,
+			expectedValue: []RunRecord{record(2), record(3)},
+		},
+		{name: "records of other format versions are skipped",
+			content:       "{\"formatVersion\":2,\"command\":\"apply\"}\n\n",
+			records:       []RunRecord{record(1)},
+			expectedValue: []RunRecord{record(1)},
+		},
+		{name: "invalid record",
+			content:       "not json\n",
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		path := filepath.Join(t.TempDir(), "history.jsonl")
+		if test.content != "" {
+			assert.NoError(t, os.WriteFile(path, []byte(test.content), 0o600))
+		}
+		for _, record := range test.records {
+			assert.NoError(t, AppendHistory(path, record))
+		}
+
+		output, err := LoadHistory(path, test.limit)
+
+		assert.Equal(t, test.expectedValue, output)
+		assert.Equal(t, test.expectedError, err != nil)
+	}
+}
+
+func TestNewUpdateRecord(t *testing.T) {
+	t.Parallel()
+
+	nodegroup := aws.NodeGroup{AccountID: "111111111111", Region: "eu-west-1", ClusterName: "cluster-1", NodegroupName: "ng-1"}
+
+	tests := []struct {
+		name          string
+		update        Update
+		dryrun        bool
+		err           error
+		expectedValue UpdateRecord
+	}{
+		{name: "applied release version is recorded",
+			update:        Update{Nodegroup: nodegroup, ReleaseVersion: "1.29.0-20240202", policy: nodegroupPolicy{release: config.ReleaseLatest}},
+			expectedValue: UpdateRecord{Account: "111111111111", Region: "eu-west-1", Cluster: "cluster-1", Nodegroup: "ng-1", Release: "1.29.0-20240202", Result: UpdateResultUpdated},
+		},
+		{name: "policy release is recorded without a release version",
+			update:        Update{Nodegroup: nodegroup, policy: nodegroupPolicy{release: config.ReleaseLatest}},
+			dryrun:        true,
+			expectedValue: UpdateRecord{Account: "111111111111", Region: "eu-west-1", Cluster: "cluster-1", Nodegroup: "ng-1", Release: "latest", Result: UpdateResultDryrun},
+		},
+		{name: "failed update",
+			update: Update{Nodegroup: nodegroup, ReleaseVersion: "1.29.0-20240202"},
+			err:    errors.New("timeout"),
+			expectedValue: UpdateRecord{Account: "111111111111", Region: "eu-west-1", Cluster: "cluster-1", Nodegroup: "ng-1", Release: "1.29.0-20240202", Result: UpdateResultFailed,
+				Phase: PhaseWait, Error: "timeout"},
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+
+		output := newUpdateRecord(test.update, test.dryrun, PhaseWait, test.err)
+
+		assert.Equal(t, test.expectedValue, output)
+	}
+}
+
+func TestPrintHistory(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+	startedAt := time.Date(2024, 2, 4, 7, 30, 0, 0, time.UTC)
+
+	err := PrintHistory(&output, []RunRecord{{
+		Command:    "apply",
+		StartedAt:  startedAt,
+		FinishedAt: startedAt.Add(12 * time.Minute),
+		Updates: []UpdateRecord{
+			{Account: "111111111111", Region: "eu-west-1", Cluster: "cluster-1", Nodegroup: "ng-1", Release: "1.29.0-20240202", Result: UpdateResultUpdated},
+			{Account: "111111111111", Region: "eu-west-1", Cluster: "cluster-1", Nodegroup: "ng-2", Release: "1.29.0-20240202", Result: UpdateResultFailed, Phase: PhaseWait, Error: "timeout"},
+		},
+		Failures:       1,
+		SkippedRegions: []string{"111111111111:me-south-1"},
+	}})
+
+	assert.NoError(t, err)
+	assert.Contains(t, output.String(), "  skipped regions: 111111111111:me-south-1\n")
+	assert.Contains(t, output.String(), "2024-02-04T07:30:00Z apply (duration: 12m0s, updates: 2, failures: 1)")
+	assert.Contains(t, output.String(), "  111111111111  eu-west-1  cluster-1  ng-2  1.29.0-20240202  failed   timeout")
+}
@@ -0,0 +1,86 @@
+package updater
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/loomhq/eks-ng-ami-updater/pkg/aws"
+	"github.com/rs/zerolog/log"
+)
+
+var ErrRunDeadline = errors.New("not enough time left before the run deadline to start the update")
+
+type inFlightUpdates struct {
+	mutex   sync.Mutex
+	started map[aws.NodeGroup]time.Time
+}
+
+func newInFlightUpdates() *inFlightUpdates {
+	return &inFlightUpdates{started: map[aws.NodeGroup]time.Time{}}
+}
+
+func (u *inFlightUpdates) start(nodegroup aws.NodeGroup) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	u.started[nodegroup] = time.Now()
+}
+
+func (u *inFlightUpdates) finish(nodegroup aws.NodeGroup) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	delete(u.started, nodegroup)
+}
+
+func (u *inFlightUpdates) report(ctx context.Context) []aws.NodeGroup {
+	logWithContext := log.Ctx(ctx).With().Str("function", "report").Logger()
+
+	// start times are copied with the nodegroups, so updates finishing meanwhile do not race with the report
+	u.mutex.Lock()
+	started := maps.Clone(u.started)
+	u.mutex.Unlock()
+
+	nodegroups := slices.Collect(maps.Keys(started))
+
+	sort.Slice(nodegroups, func(i, j int) bool {
+		a, b := nodegroups[i], nodegroups[j]
+		if a.AccountID != b.AccountID {
+			return a.AccountID < b.AccountID
+		}
+		if a.Region != b.Region {
+			return a.Region < b.Region
+		}
+		if a.ClusterName != b.ClusterName {
+			return a.ClusterName < b.ClusterName
+		}
+
+		return a.NodegroupName < b.NodegroupName
+	})
+
+	for _, nodegroup := range nodegroups {
+		logWithContext.Warn().Str("account", nodegroup.AccountID).Str("region", nodegroup.Region).Str("cluster", nodegroup.ClusterName).Str("nodegroup", nodegroup.NodegroupName).
+			Time("startedAt", started[nodegroup].UTC()).Msg("ami update is still in progress in AWS, its result is unknown")
+	}
+	logWithContext.Warn().Err(context.Cause(ctx)).Int("inFlight", len(nodegroups)).Msg("run was interrupted")
+
+	return nodegroups
+}
+
+func canStartUpdate(cutoff time.Duration, now time.Time, ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	deadline, ok := ctx.Deadline()
+	if ok && deadline.Sub(now) < cutoff {
+		return ErrRunDeadline
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package updater
+
+import (
+	"context"
+
+	"github.com/loomhq/eks-ng-ami-updater/pkg/aws"
+	"github.com/loomhq/eks-ng-ami-updater/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+type AmiState string
+
+const (
+	AmiStateCurrent  AmiState = "current"
+	AmiStateOutdated AmiState = "outdated"
+	AmiStateUnknown  AmiState = "unknown"
+)
+
+type InventoryItem struct {
+	Nodegroup     aws.NodeGroup
+	AmiType       string
+	Version       string
+	Release       string
+	LatestRelease string
+	Status        string
+	State         AmiState
+}
+
+func Inventory(options Options, clients aws.Clients, ctx context.Context) ([]InventoryItem, error) {
+	inventoryFindings := findings{strict: options.Strict}
+	cache := loadAmiCache(options, ctx)
+	resolver := aws.NewAmiResolver(cache)
+
+	logWithContext := log.Ctx(ctx).With().Str("function", "Inventory").Logger()
+
+	accounts, err := getAccounts(options, clients, &inventoryFindings, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	describedNodegroups, err := describeNodegroups(options, accounts, &inventoryFindings, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	prefetchAmiCatalog(describedNodegroups, resolver, options.DiscoveryConcurrency, ctx)
+
+	items, errs := utils.Map(describedNodegroups, options.DiscoveryConcurrency, func(described describedNodegroup) (InventoryItem, error) {
+		return newInventoryItem(described, resolver, accountContext(described.nodegroup.AccountID, ctx))
+	})
+
+	for i, described := range describedNodegroups {
+		if errs[i] != nil {
+			if err := inventoryFindings.record(described.nodegroup, PhaseDecision, errs[i], ctx); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	err = cache.Save()
+	if err != nil {
+		logWithContext.Warn().Err(err).Msg("unable to save ami cache")
+	}
+
+	return items, newMultiError(inventoryFindings.errors)
+}
+
+func newInventoryItem(described describedNodegroup, resolver *aws.AmiResolver, ctx context.Context) (InventoryItem, error) {
+	nodegroup := described.description.Nodegroup
+	item := InventoryItem{
+		Nodegroup: described.nodegroup,
+		AmiType:   string(nodegroup.AmiType),
+		Version:   *nodegroup.Version,
+		Release:   *nodegroup.ReleaseVersion,
+		Status:    string(nodegroup.Status),
+		State:     AmiStateUnknown,
+	}
+
+	latestAmi, err := resolver.Resolve(amiKey(described.nodegroup, described.description), described.clients, ctx)
+	if err != nil {
+		return item, err
+	}
+	item.LatestRelease = latestAmi.Release
+
+	isTheSameAmiVersion, err := aws.IsTheSameAmiVersion(described.nodegroup, item.AmiType, item.Release, latestAmi, ctx)
+	if err != nil {
+		return item, err
+	}
+	item.State = AmiStateOutdated
+	if isTheSameAmiVersion {
+		item.State = AmiStateCurrent
+	}
+
+	return item, nil
+}
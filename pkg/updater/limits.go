@@ -0,0 +1,53 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+type scopeLimits struct {
+	mutex  sync.Mutex
+	limits map[string]*scopeLimit
+}
+
+type scopeLimit struct {
+	scope string
+	slots chan struct{}
+}
+
+func newScopeLimits() *scopeLimits {
+	return &scopeLimits{limits: map[string]*scopeLimit{}}
+}
+
+func (l *scopeLimits) get(scope string, concurrency int) *scopeLimit {
+	if concurrency < 1 {
+		return &scopeLimit{scope: scope}
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	limit, ok := l.limits[scope]
+	if !ok {
+		limit = &scopeLimit{scope: scope, slots: make(chan struct{}, concurrency)}
+		l.limits[scope] = limit
+	}
+
+	return limit
+}
+
+func (l *scopeLimit) run(update func() (Phase, error), ctx context.Context) (Phase, error) {
+	if l.slots == nil {
+		return update()
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+	case <-ctx.Done():
+		return PhaseUpdate, fmt.Errorf("waiting for a free update slot of %s: %w", l.scope, ctx.Err())
+	}
+	defer func() { <-l.slots }()
+
+	return update()
+}
@@ -0,0 +1,42 @@
+package updater
+
+import "time"
+
+const commandApply = "apply"
+
+type Options struct {
+	Command              string
+	Dryrun               bool
+	Strict               bool
+	NoCache              bool
+	CacheFile            string
+	CacheTTL             time.Duration
+	HistoryFile          string
+	PlanFile             string
+	PlanMaxAge           time.Duration
+	SkipNewerThanDays    uint
+	DiscoveryConcurrency int
+	UpdateStartCutoff    time.Duration
+	UpdateWaitTimeout    time.Duration
+	UpdateWaitMinDelay   time.Duration
+	UpdateWaitMaxDelay   time.Duration
+	Tag                  string
+	TagSelector          string
+	ExcludeTagSelector   string
+	LabelSelector        string
+	ExcludeLabelSelector string
+	TaintSelector        string
+	ExcludeTaintSelector string
+	UpdateFirstSelector  string
+	Filter               string
+	OptIn                bool
+	ExternalID           string
+	RoleArns             []string
+	Organization         bool
+	OrganizationTag      string
+	OrganizationUnits    []string
+	RoleArnTemplate      string
+	Regions              []string
+	ExcludeRegions       []string
+	Nodegroups           []string
+}
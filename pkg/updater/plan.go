@@ -0,0 +1,156 @@
+package updater
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/loomhq/eks-ng-ami-updater/pkg/aws"
+	"github.com/loomhq/eks-ng-ami-updater/pkg/config"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/errgroup"
+)
+
+type Plan struct {
+	CreatedAt time.Time
+	Updates   []Update
+	Decisions []Decision
+	Errors    []*NodegroupError
+	// regions where eks calls are denied or which are not enabled, they are not errors of the run
+	SkippedRegions []SkippedRegion
+	accounts       map[string]aws.Clients
+	startedAt      time.Time
+}
+
+func NewPlan(options Options, policies *config.Config, clients aws.Clients, ctx context.Context) (*Plan, error) {
+	pipeline, err := NewPipeline(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPlan(options, policies, pipeline, clients, ctx)
+}
+
+func newPlan(options Options, policies *config.Config, pipeline *Pipeline, clients aws.Clients, ctx context.Context) (*Plan, error) {
+	now := time.Now().UTC()
+	plan := &Plan{CreatedAt: now, startedAt: now}
+
+	accountFindings := findings{strict: options.Strict}
+	accounts, err := getAccounts(options, clients, &accountFindings, ctx)
+	if err != nil {
+		return nil, err
+	}
+	plan.accounts = accounts
+	plan.Errors = append(plan.Errors, accountFindings.errors...)
+
+	decisions, discoveryFindings, skippedRegions, err := GetNodeGroupsToUpdateAmi(options, policies, pipeline, accounts, ctx)
+	if err != nil {
+		return nil, err
+	}
+	plan.Decisions = decisions
+	for _, decision := range decisions {
+		if decision.Update != nil {
+			plan.Updates = append(plan.Updates, *decision.Update)
+		}
+	}
+	plan.Errors = append(plan.Errors, discoveryFindings...)
+	plan.SkippedRegions = skippedRegions
+
+	return plan, nil
+}
+
+func (p *Plan) Err() error {
+	return newMultiError(slices.Clone(p.Errors))
+}
+
+func (p *Plan) Apply(options Options, ctx context.Context) error {
+	var errorGroup errgroup.Group
+	var mutex sync.Mutex
+
+	updateErrors := slices.Clone(p.Errors)
+	records := make([]UpdateRecord, len(p.Updates))
+
+	inFlight := newInFlightUpdates()
+	limits := newScopeLimits()
+	// nodegroups matching --update-first-selector are updated before the others are started
+	for _, first := range []bool{true, false} {
+		for i, update := range p.Updates {
+			if update.First != first {
+				continue
+			}
+			nodegroup, policy := update.Nodegroup, update.policy
+			limit := limits.get(policy.concurrencyScope, policy.concurrency)
+			errorGroup.Go(func() error {
+				phase, err := limit.run(func() (Phase, error) {
+					return updateNodegroup(update, options, p.accounts[nodegroup.AccountID], inFlight, accountContext(nodegroup.AccountID, ctx))
+				}, ctx)
+				records[i] = newUpdateRecord(update, options.Dryrun, phase, err)
+				if err != nil {
+					mutex.Lock()
+					updateErrors = append(updateErrors, NewNodegroupError(nodegroup, phase, err))
+					mutex.Unlock()
+				}
+
+				return nil
+			})
+		}
+		_ = errorGroup.Wait()
+	}
+
+	if ctx.Err() != nil {
+		inFlight.report(ctx)
+	}
+
+	if !options.Dryrun {
+		recordRun(options, p, records, updateErrors, ctx)
+	}
+
+	return newMultiError(updateErrors)
+}
+
+func UpdateAmi(options Options, policies *config.Config, clients aws.Clients, ctx context.Context) error {
+	var plan *Plan
+	var err error
+
+	if options.PlanFile != "" {
+		plan, err = LoadPlan(options, clients, ctx)
+	} else {
+		plan, err = NewPlan(options, policies, clients, ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	return plan.Apply(options, ctx)
+}
+
+func recordRun(options Options, plan *Plan, records []UpdateRecord, updateErrors []*NodegroupError, ctx context.Context) {
+	logWithContext := log.Ctx(ctx).With().Str("function", "recordRun").Logger()
+
+	if options.HistoryFile == "" {
+		return
+	}
+
+	command := options.Command
+	if command == "" {
+		command = commandApply
+	}
+	record := RunRecord{
+		FormatVersion: historyFormatVersion,
+		Command:       command,
+		StartedAt:     plan.startedAt,
+		FinishedAt:    time.Now().UTC(),
+		Updates:       records,
+		Failures:      len(updateErrors),
+	}
+	for _, skipped := range plan.SkippedRegions {
+		record.SkippedRegions = append(record.SkippedRegions, strings.TrimPrefix(skipped.AccountID+":"+skipped.Region, ":"))
+	}
+
+	err := AppendHistory(options.HistoryFile, record)
+	if err != nil {
+		logWithContext.Warn().Str("historyFile", options.HistoryFile).Err(err).Msg("unable to record the run in the history file")
+	}
+}
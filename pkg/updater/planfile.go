@@ -0,0 +1,251 @@
+package updater
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/loomhq/eks-ng-ami-updater/pkg/aws"
+	"github.com/loomhq/eks-ng-ami-updater/pkg/config"
+	"github.com/loomhq/eks-ng-ami-updater/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+const planFormatVersion = 1
+
+type planFile struct {
+	FormatVersion int             `json:"formatVersion"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	Nodegroups    []plannedUpdate `json:"nodegroups"`
+}
+
+type plannedUpdate struct {
+	Account        string        `json:"account"`
+	Region         string        `json:"region"`
+	Cluster        string        `json:"cluster"`
+	Nodegroup      string        `json:"nodegroup"`
+	AmiType        string        `json:"amiType"`
+	Version        string        `json:"version"`
+	CurrentRelease string        `json:"currentRelease"`
+	TargetRelease  string        `json:"targetRelease"`
+	ReleaseVersion string        `json:"releaseVersion,omitempty"`
+	AmiID          string        `json:"amiId,omitempty"`
+	Reason         string        `json:"reason"`
+	First          bool          `json:"first,omitempty"`
+	Policy         plannedPolicy `json:"policy"`
+}
+
+type plannedPolicy struct {
+	SkipNewerThanDays uint     `json:"skipNewerThanDays"`
+	Release           string   `json:"release"`
+	Force             bool     `json:"force"`
+	Concurrency       int      `json:"concurrency,omitempty"`
+	ConcurrencyScope  string   `json:"concurrencyScope,omitempty"`
+	Blocks            []string `json:"blocks,omitempty"`
+}
+
+func (p *Plan) Save(path string) error {
+	file := planFile{FormatVersion: planFormatVersion, CreatedAt: p.CreatedAt, Nodegroups: []plannedUpdate{}}
+	for _, update := range p.Updates {
+		policy := update.policy
+		file.Nodegroups = append(file.Nodegroups, plannedUpdate{
+			Account:        update.Nodegroup.AccountID,
+			Region:         update.Nodegroup.Region,
+			Cluster:        update.Nodegroup.ClusterName,
+			Nodegroup:      update.Nodegroup.NodegroupName,
+			AmiType:        update.AmiType,
+			Version:        update.Version,
+			CurrentRelease: update.CurrentRelease,
+			TargetRelease:  update.TargetRelease,
+			ReleaseVersion: update.ReleaseVersion,
+			AmiID:          update.AmiID,
+			Reason:         update.Reason,
+			First:          update.First,
+			Policy: plannedPolicy{
+				SkipNewerThanDays: policy.skipNewerThanDays,
+				Release:           policy.release,
+				Force:             policy.force,
+				Concurrency:       policy.concurrency,
+				ConcurrencyScope:  policy.concurrencyScope,
+				Blocks:            policy.blocks,
+			},
+		})
+	}
+
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding plan: %w", err)
+	}
+
+	err = os.WriteFile(path, append(data, '\n'), 0o600)
+	if err != nil {
+		return fmt.Errorf("error writing plan file %s: %w", path, err)
+	}
+
+	return nil
+}
+
+func LoadPlan(options Options, clients aws.Clients, ctx context.Context) (*Plan, error) {
+	logWithContext := log.Ctx(ctx).With().Str("function", "LoadPlan").Logger()
+
+	plan, err := readPlanFile(options.PlanFile, options.PlanMaxAge, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	accounts, err := getPlanAccounts(plan, options, clients, ctx)
+	if err != nil {
+		return nil, err
+	}
+	plan.accounts = accounts
+
+	// a nodegroup opted out or put into a state which does not allow an update after review is drift as well
+	filters := []Filter{statusFilter{}, enabledFilter{optIn: options.OptIn}}
+	resolver := aws.NewAmiResolver(nil)
+	_, errs := utils.Map(plan.Updates, options.DiscoveryConcurrency, func(update Update) (struct{}, error) {
+		return struct{}{}, checkPlanDrift(update, accounts, filters, resolver, accountContext(update.Nodegroup.AccountID, ctx))
+	})
+
+	var driftErrors []error
+	for i, update := range plan.Updates {
+		if errs[i] != nil {
+			driftErrors = append(driftErrors, NewNodegroupError(update.Nodegroup, PhaseDecision, errs[i]))
+		}
+	}
+	if len(driftErrors) > 0 {
+		return nil, fmt.Errorf("plan file %s does not match the nodegroups anymore, create a new plan: %w", options.PlanFile, errors.Join(driftErrors...))
+	}
+
+	logWithContext.Info().Str("planFile", options.PlanFile).Time("createdAt", plan.CreatedAt).Int("nodegroups", len(plan.Updates)).Msg("plan file matches the nodegroups")
+
+	return plan, nil
+}
+
+func getPlanAccounts(plan *Plan, options Options, clients aws.Clients, ctx context.Context) (map[string]aws.Clients, error) {
+	roleArns, err := getRoleArns(options, clients, ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(roleArns) == 0 && !options.Organization {
+		return getAccounts(options, clients, &findings{strict: true}, ctx)
+	}
+
+	var planAccounts []string
+	for _, update := range plan.Updates {
+		if !slices.Contains(planAccounts, update.Nodegroup.AccountID) {
+			planAccounts = append(planAccounts, update.Nodegroup.AccountID)
+		}
+	}
+
+	// only the accounts of the plan are assumed and every one of them has to be reachable,
+	// a role of an account without planned nodegroups does not stop the run
+	partition := aws.PartitionForRegion(clients.DefaultRegion())
+	roleArns = slices.DeleteFunc(roleArns, func(roleArn string) bool {
+		accountID, err := partition.RoleArnAccountID(roleArn)
+
+		return err == nil && !slices.Contains(planAccounts, accountID)
+	})
+
+	return assumeRoles(roleArns, options.ExternalID, clients, &findings{strict: true}, ctx)
+}
+
+func readPlanFile(path string, maxAge time.Duration, now time.Time) (*Plan, error) {
+	var file planFile
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading plan file %s: %w", path, err)
+	}
+
+	err = json.Unmarshal(data, &file)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing plan file %s: %w", path, err)
+	}
+	if file.FormatVersion != planFormatVersion {
+		return nil, fmt.Errorf("plan file %s has format version %d, only version %d is supported", path, file.FormatVersion, planFormatVersion)
+	}
+	if maxAge > 0 && now.Sub(file.CreatedAt) > maxAge {
+		return nil, fmt.Errorf("plan file %s was created at %s and expired after %s, create a new plan", path, file.CreatedAt.UTC().Format(time.RFC3339), maxAge)
+	}
+
+	plan := &Plan{CreatedAt: file.CreatedAt, startedAt: now.UTC()}
+	for _, planned := range file.Nodegroups {
+		plan.Updates = append(plan.Updates, Update{
+			Nodegroup:      aws.NodeGroup{AccountID: planned.Account, Region: planned.Region, ClusterName: planned.Cluster, NodegroupName: planned.Nodegroup},
+			AmiType:        planned.AmiType,
+			Version:        planned.Version,
+			CurrentRelease: planned.CurrentRelease,
+			TargetRelease:  planned.TargetRelease,
+			ReleaseVersion: planned.ReleaseVersion,
+			AmiID:          planned.AmiID,
+			Reason:         planned.Reason,
+			First:          planned.First,
+			policy: nodegroupPolicy{
+				skipNewerThanDays: planned.Policy.SkipNewerThanDays,
+				release:           planned.Policy.Release,
+				force:             planned.Policy.Force,
+				concurrency:       planned.Policy.Concurrency,
+				concurrencyScope:  planned.Policy.ConcurrencyScope,
+				blocks:            planned.Policy.Blocks,
+			},
+		})
+	}
+
+	return plan, nil
+}
+
+func checkPlanDrift(update Update, accounts map[string]aws.Clients, filters []Filter, resolver *aws.AmiResolver, ctx context.Context) error {
+	accountClients, err := getAccountClients(accounts, update.Nodegroup.AccountID)
+	if err != nil {
+		return err
+	}
+
+	awsEks, err := accountClients.Eks(update.Nodegroup.Region)
+	if err != nil {
+		return err
+	}
+
+	description, err := aws.GetNodegroupDescription(update.Nodegroup, awsEks, ctx)
+	if err != nil {
+		return err
+	}
+
+	nodegroup := description.Nodegroup
+	switch {
+	case string(nodegroup.AmiType) != update.AmiType:
+		return fmt.Errorf("ami type has changed from %s to %s", update.AmiType, nodegroup.AmiType)
+	case *nodegroup.Version != update.Version:
+		return fmt.Errorf("kubernetes version has changed from %s to %s", update.Version, *nodegroup.Version)
+	case *nodegroup.ReleaseVersion != update.CurrentRelease:
+		return fmt.Errorf("release has changed from %s to %s", update.CurrentRelease, *nodegroup.ReleaseVersion)
+	}
+
+	candidate := &Candidate{Nodegroup: update.Nodegroup, Description: nodegroup, policy: update.policy}
+	for _, filter := range filters {
+		result, err := filter.Filter(candidate, ctx)
+		if err != nil {
+			return err
+		}
+		if result.Verdict != VerdictKeep {
+			return fmt.Errorf("%s check does not keep the nodegroup anymore: %s", filter.Name(), result.Reason)
+		}
+	}
+
+	// eks updates to the latest ami when no release is pinned, so it has to be the planned one
+	if update.policy.release != config.ReleaseLatest {
+		return nil
+	}
+	latestAmi, err := resolver.Resolve(amiKey(update.Nodegroup, description), accountClients, ctx)
+	if err != nil {
+		return err
+	}
+	if latestAmi.ImageID != update.AmiID {
+		return fmt.Errorf("latest ami has changed from %s (%s) to %s (%s)", update.AmiID, update.TargetRelease, latestAmi.ImageID, latestAmi.Release)
+	}
+
+	return nil
+}
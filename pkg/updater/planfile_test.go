@@ -0,0 +1,197 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	awsLib "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	eksTypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/aws/smithy-go"
+	"github.com/loomhq/eks-ng-ami-updater/pkg/aws"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanFile(t *testing.T) {
+	t.Parallel()
+
+	createdAt := time.Date(2024, 2, 2, 10, 0, 0, 0, time.UTC)
+	plan := &Plan{CreatedAt: createdAt, Updates: []Update{{
+		Nodegroup:      aws.NodeGroup{AccountID: "111111111111", Region: "eu-west-1", ClusterName: "cluster-1", NodegroupName: "ng-1"},
+		AmiType:        "AL2_x86_64",
+		Version:        "1.29",
+		CurrentRelease: "1.29.0-20240117",
+		TargetRelease:  "1.29.0-20240202",
+		ReleaseVersion: "1.29.0-20240202",
+		Reason:         "pinned release is not in use",
+		First:          true,
+		policy:         nodegroupPolicy{release: "1.29.0-20240202", force: true, concurrency: 1, concurrencyScope: "overrides[0]", blocks: []string{"defaults", "overrides[0]"}},
+	}}}
+
+	tests := []struct {
+		name          string
+		content       string
+		maxAge        time.Duration
+		now           time.Time
+		expectedValue []Update
+		expectedError string
+	}{
+		{name: "saved plan is read back",
+			maxAge:        24 * time.Hour,
+			now:           createdAt.Add(time.Hour),
+			expectedValue: plan.Updates,
+		},
+		{name: "expiry is disabled",
+			now:           createdAt.Add(30 * 24 * time.Hour),
+			expectedValue: plan.Updates,
+		},
+		{name: "expired plan",
+			maxAge:        24 * time.Hour,
+			now:           createdAt.Add(25 * time.Hour),
+			expectedError: "was created at 2024-02-02T10:00:00Z and expired after 24h0m0s",
+		},
+		{name: "unsupported format version",
+			content:       `{"formatVersion": 2, "nodegroups": []}`,
+			expectedError: "has format version 2, only version 1 is supported",
+		},
+		{name: "invalid json",
+			content:       `{"formatVersion": `,
+			expectedError: "error parsing plan file",
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		path := filepath.Join(t.TempDir(), "plan.json")
+		if test.content != "" {
+			assert.NoError(t, os.WriteFile(path, []byte(test.content), 0o600))
+		} else {
+			assert.NoError(t, plan.Save(path))
+		}
+
+		output, err := readPlanFile(path, test.maxAge, test.now)
+
+		if test.expectedError != "" {
+			assert.ErrorContains(t, err, test.expectedError)
+
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, createdAt, output.CreatedAt)
+		assert.Equal(t, test.expectedValue, output.Updates)
+	}
+}
+
+func TestCheckPlanDrift(t *testing.T) {
+	t.Parallel()
+
+	nodegroup := aws.NodeGroup{AccountID: "111111111111", Region: "eu-west-1", ClusterName: "cluster-1", NodegroupName: "ng-1"}
+	update := Update{Nodegroup: nodegroup, AmiType: "AL2_x86_64", Version: "1.29", CurrentRelease: "1.29.0-20240117", policy: nodegroupPolicy{release: "1.29.0-20240202"}}
+
+	tests := []struct {
+		name          string
+		accountID     string
+		live          eksTypes.Nodegroup
+		expectedError string
+	}{
+		{name: "nodegroup matches the plan",
+			accountID: "111111111111",
+			live:      eksTypes.Nodegroup{AmiType: eksTypes.AMITypesAl2X8664, Version: awsLib.String("1.29"), ReleaseVersion: awsLib.String("1.29.0-20240117"), Status: eksTypes.NodegroupStatusActive},
+		},
+		{name: "nodegroup has been opted out",
+			accountID: "111111111111",
+			live: eksTypes.Nodegroup{AmiType: eksTypes.AMITypesAl2X8664, Version: awsLib.String("1.29"), ReleaseVersion: awsLib.String("1.29.0-20240117"), Status: eksTypes.NodegroupStatusActive, NodegroupName: awsLib.String("ng-1"),
+				Tags: map[string]string{"eks-ng-ami-updater/enabled": "false"}},
+			expectedError: "enabled check does not keep the nodegroup anymore: nodegroup is opted out with the 'eks-ng-ami-updater/enabled=false' tag",
+		},
+		{name: "nodegroup status does not allow an update anymore",
+			accountID:     "111111111111",
+			live:          eksTypes.Nodegroup{AmiType: eksTypes.AMITypesAl2X8664, Version: awsLib.String("1.29"), ReleaseVersion: awsLib.String("1.29.0-20240117"), Status: eksTypes.NodegroupStatusUpdating},
+			expectedError: "status check does not keep the nodegroup anymore: nodegroup status UPDATING does not allow an ami update yet",
+		},
+		{name: "release has changed",
+			accountID:     "111111111111",
+			live:          eksTypes.Nodegroup{AmiType: eksTypes.AMITypesAl2X8664, Version: awsLib.String("1.29"), ReleaseVersion: awsLib.String("1.29.0-20240202")},
+			expectedError: "release has changed from 1.29.0-20240117 to 1.29.0-20240202",
+		},
+		{name: "kubernetes version has changed",
+			accountID:     "111111111111",
+			live:          eksTypes.Nodegroup{AmiType: eksTypes.AMITypesAl2X8664, Version: awsLib.String("1.30"), ReleaseVersion: awsLib.String("1.30.0-20240202")},
+			expectedError: "kubernetes version has changed from 1.29 to 1.30",
+		},
+		{name: "account is not checked anymore",
+			accountID:     "222222222222",
+			live:          eksTypes.Nodegroup{AmiType: eksTypes.AMITypesAl2X8664, Version: awsLib.String("1.29"), ReleaseVersion: awsLib.String("1.29.0-20240117")},
+			expectedError: "account (111111111111) is not one of the '--role-arns' accounts",
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		accounts := map[string]aws.Clients{test.accountID: aws.TestClients{AwsEks: aws.TestEks{OutputDescribeNodegroup: &eks.DescribeNodegroupOutput{Nodegroup: &test.live}}}}
+
+		err := checkPlanDrift(update, accounts, []Filter{statusFilter{}, enabledFilter{}}, nil, context.Background())
+
+		if test.expectedError != "" {
+			assert.ErrorContains(t, err, test.expectedError)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+}
+
+func TestLoadPlanAccounts(t *testing.T) {
+	t.Parallel()
+
+	errAccessDenied := &smithy.GenericAPIError{Code: "AccessDenied", Message: "not authorized to perform sts:AssumeRole"}
+	plan := &Plan{CreatedAt: time.Now().UTC(), Updates: []Update{{
+		Nodegroup:      aws.NodeGroup{AccountID: "222222222222", Region: "eu-west-1", ClusterName: "cluster-1", NodegroupName: "ng-1"},
+		AmiType:        "AL2_x86_64",
+		Version:        "1.29",
+		CurrentRelease: "1.29.0-20240117",
+		ReleaseVersion: "1.29.0-20240202",
+		policy:         nodegroupPolicy{release: "1.29.0-20240202"},
+	}}}
+	roleArns := []string{"arn:aws:iam::222222222222:role/eks-ng-ami-updater", "arn:aws:iam::333333333333:role/eks-ng-ami-updater"}
+
+	tests := []struct {
+		name          string
+		deniedRoleArn string
+		expectedError string
+	}{
+		{name: "role of an account without planned nodegroups cannot be assumed",
+			deniedRoleArn: "arn:aws:iam::333333333333:role/eks-ng-ami-updater",
+		},
+		{name: "role of a planned account cannot be assumed",
+			deniedRoleArn: "arn:aws:iam::222222222222:role/eks-ng-ami-updater",
+			expectedError: "not authorized to perform sts:AssumeRole",
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		path := filepath.Join(t.TempDir(), "plan.json")
+		assert.NoError(t, plan.Save(path))
+		clients := aws.TestClients{
+			Account: "111111111111",
+			AwsEks: aws.TestEks{OutputDescribeNodegroup: &eks.DescribeNodegroupOutput{Nodegroup: &eksTypes.Nodegroup{
+				NodegroupName: awsLib.String("ng-1"), AmiType: eksTypes.AMITypesAl2X8664, Version: awsLib.String("1.29"), ReleaseVersion: awsLib.String("1.29.0-20240117"),
+				Status: eksTypes.NodegroupStatusActive,
+			}}},
+			ErrAssumeRoleArns: map[string]error{test.deniedRoleArn: errAccessDenied},
+		}
+		options := Options{PlanFile: path, RoleArns: roleArns, Dryrun: true, DiscoveryConcurrency: 1}
+
+		err := UpdateAmi(options, nil, clients, context.Background())
+
+		if test.expectedError != "" {
+			assert.ErrorContains(t, err, test.expectedError)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package updater
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/loomhq/eks-ng-ami-updater/pkg/aws"
+)
+
+func PrintPlan(w io.Writer, plan *Plan) error {
+	if len(plan.Updates) == 0 {
+		fmt.Fprintln(w, "no nodegroups are ready for ami update")
+	} else {
+		writer := newTableWriter(w)
+		fmt.Fprintln(writer, "ACCOUNT\tREGION\tCLUSTER\tNODEGROUP\tCURRENT\tTARGET\tAMI\tFORCE\tREASON")
+		for _, update := range plan.Updates {
+			nodegroup := update.Nodegroup
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%t\t%s\n", orNone(nodegroup.AccountID), nodegroup.Region, nodegroup.ClusterName, nodegroup.NodegroupName,
+				update.CurrentRelease, update.TargetRelease, orNone(update.AmiID), update.policy.force, update.Reason)
+		}
+		err := writer.Flush()
+		if err != nil {
+			return err
+		}
+	}
+
+	return printSkippedRegions(w, plan.SkippedRegions)
+}
+
+func printSkippedRegions(w io.Writer, skippedRegions []SkippedRegion) error {
+	if len(skippedRegions) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(w, "\nskipped regions (eks calls are denied or the region is not enabled):")
+	writer := newTableWriter(w)
+	for _, skipped := range skippedRegions {
+		fmt.Fprintf(writer, "  %s\t%s\t%s\n", orNone(skipped.AccountID), skipped.Region, orNone(skipped.Code))
+	}
+
+	return writer.Flush()
+}
+
+func PrintInventory(w io.Writer, items []InventoryItem) error {
+	writer := newTableWriter(w)
+	fmt.Fprintln(writer, "ACCOUNT\tREGION\tCLUSTER\tNODEGROUP\tAMI TYPE\tVERSION\tRELEASE\tLATEST\tSTATE\tSTATUS")
+	for _, item := range items {
+		nodegroup := item.Nodegroup
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", orNone(nodegroup.AccountID), nodegroup.Region, nodegroup.ClusterName, nodegroup.NodegroupName,
+			item.AmiType, item.Version, item.Release, orNone(item.LatestRelease), item.State, item.Status)
+	}
+
+	return writer.Flush()
+}
+
+func PrintStatus(w io.Writer, updates []InFlightUpdate) error {
+	if len(updates) == 0 {
+		_, err := fmt.Fprintln(w, "no ami updates are in progress")
+
+		return err
+	}
+
+	writer := newTableWriter(w)
+	fmt.Fprintln(writer, "ACCOUNT\tREGION\tCLUSTER\tNODEGROUP\tRELEASE\tUPDATE\tTYPE\tSTARTED")
+	for _, update := range updates {
+		nodegroup := update.Nodegroup
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", orNone(nodegroup.AccountID), nodegroup.Region, nodegroup.ClusterName, nodegroup.NodegroupName,
+			update.Release, orNone(update.UpdateID), orNone(update.Type), formatTime(update.StartedAt))
+	}
+
+	return writer.Flush()
+}
+
+func PrintHistory(w io.Writer, records []RunRecord) error {
+	if len(records) == 0 {
+		_, err := fmt.Fprintln(w, "no runs are recorded")
+
+		return err
+	}
+
+	for i, record := range records {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s %s (duration: %s, updates: %d, failures: %d)\n", formatTime(record.StartedAt), record.Command,
+			record.FinishedAt.Sub(record.StartedAt).Round(time.Second), len(record.Updates), record.Failures)
+
+		if len(record.SkippedRegions) > 0 {
+			fmt.Fprintf(w, "  skipped regions: %s\n", strings.Join(record.SkippedRegions, ", "))
+		}
+
+		writer := newTableWriter(w)
+		for _, update := range record.Updates {
+			fmt.Fprintf(writer, "  %s\t%s\t%s\t%s\t%s\t%s\t%s\n", orNone(update.Account), update.Region, update.Cluster, update.Nodegroup, update.Release, update.Result, update.Error)
+		}
+		err := writer.Flush()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func PrintDecisions(w io.Writer, decisions []Decision) error {
+	if len(decisions) == 0 {
+		_, err := fmt.Fprintln(w, "no nodegroups were checked, see the logged errors")
+
+		return err
+	}
+
+	for i, decision := range decisions {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s: %s\n", nodegroupID(decision.Nodegroup), decisionOutcome(decision))
+		fmt.Fprintf(w, "  policy: %s\n", policySummary(decision.policy))
+		if len(decision.TagOverrides) > 0 {
+			fmt.Fprintf(w, "  tag overrides: %s\n", formatInputs(decision.TagOverrides))
+		}
+
+		writer := newTableWriter(w)
+		fmt.Fprintln(writer, "  CHECK\tVERDICT\tREASON\tINPUTS")
+		for _, check := range decision.Checks {
+			fmt.Fprintf(writer, "  %s\t%s\t%s\t%s\n", check.Name, check.Verdict, check.Reason, formatInputs(check.Inputs))
+		}
+		err := writer.Flush()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func decisionOutcome(decision Decision) string {
+	switch {
+	case decision.Err != nil:
+		return fmt.Sprintf("error (%v)", decision.Err)
+	case decision.Update != nil:
+		return fmt.Sprintf("update from %s to %s", decision.Update.CurrentRelease, decision.Update.TargetRelease)
+	case len(decision.Checks) > 0:
+		return fmt.Sprintf("%s (%s)", decision.Verdict, decision.Checks[len(decision.Checks)-1].Reason)
+	default:
+		return string(decision.Verdict)
+	}
+}
+
+func policySummary(policy nodegroupPolicy) string {
+	concurrency := "unlimited"
+	if policy.concurrency > 0 {
+		concurrency = strconv.Itoa(policy.concurrency)
+	}
+	summary := fmt.Sprintf("release %s, skip newer than %d days, force %t, concurrency %s", policy.release, policy.skipNewerThanDays, policy.force, concurrency)
+	if len(policy.blocks) > 0 {
+		summary += fmt.Sprintf(" (config: %s)", strings.Join(policy.blocks, ", "))
+	}
+
+	return summary
+}
+
+func formatInputs(inputs map[string]string) string {
+	pairs := make([]string, 0, len(inputs))
+	for key, value := range inputs {
+		pairs = append(pairs, key+"="+orNone(value))
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, " ")
+}
+
+func nodegroupID(nodegroup aws.NodeGroup) string {
+	id := nodegroup.Region + ":" + nodegroup.ClusterName + ":" + nodegroup.NodegroupName
+	if nodegroup.AccountID != "" {
+		id = nodegroup.AccountID + ":" + id
+	}
+
+	return id
+}
+
+func newTableWriter(w io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+
+	return t.UTC().Format(time.RFC3339)
+}
+
+func orNone(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
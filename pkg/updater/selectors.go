@@ -0,0 +1,92 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/loomhq/eks-ng-ami-updater/pkg/selector"
+)
+
+type selectorFilter struct {
+	name       string
+	subject    string
+	expression string
+	exclude    bool
+	values     func(candidate *Candidate) map[string][]string
+	selector   selector.Selector
+}
+
+func (f *selectorFilter) parse() error {
+	parsed, err := selector.Parse(f.expression)
+	if err != nil {
+		return fmt.Errorf("--%s: %w", f.name, err)
+	}
+	f.selector = parsed
+
+	return nil
+}
+
+func (f selectorFilter) Name() string {
+	return f.name
+}
+
+func (f selectorFilter) Filter(candidate *Candidate, ctx context.Context) (FilterResult, error) {
+	result := FilterResult{Verdict: VerdictKeep, Inputs: map[string]string{"selector": f.selector.String()}}
+
+	mismatch, hasMismatch := f.selector.FirstMismatch(f.values(candidate))
+	switch {
+	case f.exclude && hasMismatch:
+		result.Reason = fmt.Sprintf("nodegroup %s do not match the exclude selector ('%s' is not met)", f.subject, mismatch)
+	case f.exclude:
+		result.Verdict, result.Reason = VerdictSkip, fmt.Sprintf("nodegroup %s match the exclude selector", f.subject)
+	case hasMismatch:
+		result.Verdict, result.Reason = VerdictSkip, fmt.Sprintf("nodegroup %s do not match the selector ('%s' is not met)", f.subject, mismatch)
+	default:
+		result.Reason = fmt.Sprintf("nodegroup %s match the selector", f.subject)
+	}
+
+	return result, nil
+}
+
+func nodegroupTags(candidate *Candidate) map[string][]string {
+	return singleValues(candidate.Description.Tags)
+}
+
+func nodegroupLabels(candidate *Candidate) map[string][]string {
+	return singleValues(candidate.Description.Labels)
+}
+
+func nodegroupTaints(candidate *Candidate) map[string][]string {
+	taints := map[string][]string{}
+
+	// taints are matched like 'kubectl taint' writes them, eg. 'dedicated=gpu:NoSchedule',
+	// a key can be tainted with several effects, so every taint of the key is kept
+	for _, taint := range candidate.Description.Taints {
+		key := valueOf(taint.Key)
+		taints[key] = append(taints[key], valueOf(taint.Value)+":"+taintEffect(string(taint.Effect)))
+	}
+
+	return taints
+}
+
+func singleValues(values map[string]string) map[string][]string {
+	multiValues := make(map[string][]string, len(values))
+	for key, value := range values {
+		multiValues[key] = []string{value}
+	}
+
+	return multiValues
+}
+
+func taintEffect(effect string) string {
+	// eks returns effects like NO_SCHEDULE, kubernetes calls them NoSchedule
+	words := strings.Split(strings.ToLower(effect), "_")
+	for i, word := range words {
+		if word != "" {
+			words[i] = strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+
+	return strings.Join(words, "")
+}
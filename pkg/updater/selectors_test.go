@@ -0,0 +1,120 @@
+package updater
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	awsLib "github.com/aws/aws-sdk-go-v2/aws"
+	eksTypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectorFilter(t *testing.T) {
+	t.Parallel()
+
+	candidate := &Candidate{Description: &eksTypes.Nodegroup{
+		Tags:   map[string]string{"env": "prod", "team": "platform"},
+		Labels: map[string]string{"role": "system"},
+		Taints: []eksTypes.Taint{
+			{Key: awsLib.String("dedicated"), Value: awsLib.String("gpu"), Effect: eksTypes.TaintEffectNoSchedule},
+			{Key: awsLib.String("dedicated"), Value: awsLib.String("gpu"), Effect: eksTypes.TaintEffectNoExecute},
+			{Key: awsLib.String("spot"), Effect: eksTypes.TaintEffectPreferNoSchedule},
+		},
+	}}
+
+	tests := []struct {
+		name            string
+		subject         string
+		expression      string
+		exclude         bool
+		expectedVerdict Verdict
+		expectedReason  string
+	}{
+		{name: "tags match the selector",
+			expression:      "env in (prod,stage),team",
+			expectedVerdict: VerdictKeep,
+			expectedReason:  "nodegroup tags match the selector",
+		},
+		{name: "tags do not match the selector",
+			expression:      "env in (prod,stage),!team",
+			expectedVerdict: VerdictSkip,
+			expectedReason:  "nodegroup tags do not match the selector ('!team' is not met)",
+		},
+		{name: "tags match the exclude selector",
+			expression:      "team=platform",
+			exclude:         true,
+			expectedVerdict: VerdictSkip,
+			expectedReason:  "nodegroup tags match the exclude selector",
+		},
+		{name: "tags do not match the exclude selector",
+			expression:      "skip-ami-update",
+			exclude:         true,
+			expectedVerdict: VerdictKeep,
+			expectedReason:  "nodegroup tags do not match the exclude selector ('skip-ami-update' is not met)",
+		},
+		{name: "labels match the selector",
+			subject:         "labels",
+			expression:      "role=system",
+			expectedVerdict: VerdictKeep,
+			expectedReason:  "nodegroup labels match the selector",
+		},
+		{name: "labels do not match the selector",
+			subject:         "labels",
+			expression:      "role in (batch,ml)",
+			expectedVerdict: VerdictSkip,
+			expectedReason:  "nodegroup labels do not match the selector ('role in (batch,ml)' is not met)",
+		},
+		{name: "taints match the exclude selector",
+			subject:         "taints",
+			expression:      "dedicated=gpu:NoSchedule",
+			exclude:         true,
+			expectedVerdict: VerdictSkip,
+			expectedReason:  "nodegroup taints match the exclude selector",
+		},
+		{name: "taint without a value",
+			subject:         "taints",
+			expression:      "spot=:PreferNoSchedule",
+			expectedVerdict: VerdictKeep,
+			expectedReason:  "nodegroup taints match the selector",
+		},
+		{name: "second taint of a key matches the exclude selector",
+			subject:         "taints",
+			expression:      "dedicated=gpu:NoExecute",
+			exclude:         true,
+			expectedVerdict: VerdictSkip,
+			expectedReason:  "nodegroup taints match the exclude selector",
+		},
+		{name: "taint effect does not match",
+			subject:         "taints",
+			expression:      "dedicated=gpu:PreferNoSchedule",
+			exclude:         true,
+			expectedVerdict: VerdictKeep,
+			expectedReason:  "nodegroup taints do not match the exclude selector ('dedicated=gpu:PreferNoSchedule' is not met)",
+		},
+		{name: "no taint of a key may match a negative requirement",
+			subject:         "taints",
+			expression:      "dedicated!=gpu:NoExecute",
+			expectedVerdict: VerdictSkip,
+			expectedReason:  "nodegroup taints do not match the selector ('dedicated!=gpu:NoExecute' is not met)",
+		},
+	}
+
+	values := map[string]func(candidate *Candidate) map[string][]string{"": nodegroupTags, "labels": nodegroupLabels, "taints": nodegroupTaints}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		subject := "tags"
+		if test.subject != "" {
+			subject = test.subject
+		}
+		filter := selectorFilter{name: CheckTagSelector, subject: subject, expression: test.expression, exclude: test.exclude, values: values[test.subject]}
+		assert.NoError(t, filter.parse())
+
+		result, err := filter.Filter(candidate, context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, test.expectedVerdict, result.Verdict)
+		assert.Equal(t, test.expectedReason, result.Reason)
+	}
+}
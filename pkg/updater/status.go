@@ -0,0 +1,73 @@
+package updater
+
+import (
+	"context"
+	"time"
+
+	eksTypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+	"github.com/loomhq/eks-ng-ami-updater/pkg/aws"
+	"github.com/loomhq/eks-ng-ami-updater/pkg/utils"
+)
+
+type InFlightUpdate struct {
+	Nodegroup aws.NodeGroup
+	Release   string
+	UpdateID  string
+	Type      string
+	StartedAt time.Time
+}
+
+func Status(options Options, clients aws.Clients, ctx context.Context) ([]InFlightUpdate, error) {
+	var inFlightUpdates []InFlightUpdate
+	var updating []describedNodegroup
+
+	statusFindings := findings{strict: options.Strict}
+	accounts, err := getAccounts(options, clients, &statusFindings, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	describedNodegroups, err := describeNodegroups(options, accounts, &statusFindings, ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, described := range describedNodegroups {
+		if described.description.Nodegroup.Status == eksTypes.NodegroupStatusUpdating {
+			updating = append(updating, described)
+		}
+	}
+
+	updates, errs := utils.Map(updating, options.DiscoveryConcurrency, func(described describedNodegroup) ([]eksTypes.Update, error) {
+		awsEks, err := accounts[described.nodegroup.AccountID].Eks(described.nodegroup.Region)
+		if err != nil {
+			return nil, err
+		}
+
+		return aws.GetInProgressUpdates(described.nodegroup, awsEks, accountContext(described.nodegroup.AccountID, ctx))
+	})
+
+	for i, described := range updating {
+		if errs[i] != nil {
+			if err := statusFindings.record(described.nodegroup, PhaseDiscovery, errs[i], ctx); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+		release := *described.description.Nodegroup.ReleaseVersion
+		if len(updates[i]) == 0 {
+			inFlightUpdates = append(inFlightUpdates, InFlightUpdate{Nodegroup: described.nodegroup, Release: release})
+		}
+		for _, update := range updates[i] {
+			inFlightUpdates = append(inFlightUpdates, InFlightUpdate{
+				Nodegroup: described.nodegroup,
+				Release:   release,
+				UpdateID:  *update.Id,
+				Type:      string(update.Type),
+				StartedAt: *update.CreatedAt,
+			})
+		}
+	}
+
+	return inFlightUpdates, newMultiError(statusFindings.errors)
+}
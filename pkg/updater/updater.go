@@ -2,146 +2,543 @@ package updater
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/service/eks"
 	"github.com/loomhq/eks-ng-ami-updater/pkg/aws"
+	"github.com/loomhq/eks-ng-ami-updater/pkg/config"
 	"github.com/loomhq/eks-ng-ami-updater/pkg/utils"
-	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
-	"golang.org/x/sync/errgroup"
 )
 
-func GetNodeGroupsToUpdateAmi(skipNewerThanDays uint, regionsVar, nodegroupsVar []string, tagVar string, ctx context.Context) ([]aws.NodeGroup, error) {
+type regionNodegroups struct {
+	nodegroups    []aws.NodeGroup
+	clusterErrors []*aws.ClusterError
+	unavailable   error
+}
+
+type describedNodegroup struct {
+	nodegroup   aws.NodeGroup
+	description eks.DescribeNodegroupOutput
+	policy      nodegroupPolicy
+	clients     aws.Clients
+}
+
+type Update struct {
+	Nodegroup      aws.NodeGroup
+	AmiType        string
+	Version        string
+	CurrentRelease string
+	TargetRelease  string
+	// release version eks is asked for, so the update does not move on when a newer ami is published meanwhile
+	ReleaseVersion string
+	AmiID          string
+	Reason         string
+	First          bool // matches --update-first-selector, so it is updated before the other nodegroups
+	policy         nodegroupPolicy
+}
+
+type nodegroupPolicy struct {
+	skipNewerThanDays uint
+	release           string
+	force             bool
+	concurrency       int
+	concurrencyScope  string
+	blocks            []string
+}
+
+func GetNodeGroupsToUpdateAmi(options Options, policies *config.Config, pipeline *Pipeline, accounts map[string]aws.Clients, ctx context.Context) ([]Decision, []*NodegroupError, []SkippedRegion, error) {
+	var decisions []Decision
+
+	discoveryFindings := findings{strict: options.Strict}
+	cache := loadAmiCache(options, ctx)
+	resolver := aws.NewAmiResolver(cache)
+
+	logWithContext := log.Ctx(ctx).With().Str("function", "GetNodeGroupsToUpdateAmi").Logger()
+
+	describedNodegroups, err := describeNodegroups(options, accounts, &discoveryFindings, ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	prefetchAmiCatalog(describedNodegroups, resolver, options.DiscoveryConcurrency, ctx)
+
+	for i, described := range describedNodegroups {
+		describedNodegroups[i].policy = resolvePolicy(described.nodegroup, options, policies)
+	}
+
+	nodegroupDecisions, errs := utils.MapContext(describedNodegroups, utils.NewLimiter(options.DiscoveryConcurrency), options.Strict, func(described describedNodegroup, ctx context.Context) (Decision, error) {
+		decision := pipeline.decide(described, resolver, accountContext(described.nodegroup.AccountID, ctx))
+
+		return decision, decision.Err
+	}, ctx)
+
+	readyNodegroups := 0
+	for i, decision := range nodegroupDecisions {
+		nodegroup := decision.Nodegroup
+		decisions = append(decisions, decision)
+		if errs[i] != nil {
+			if err := discoveryFindings.record(nodegroup, PhaseDecision, errs[i], ctx); err != nil {
+				return nil, nil, nil, err
+			}
+
+			continue
+		}
+		if decision.Verdict == VerdictDefer {
+			logWithContext.Info().Str("account", nodegroup.AccountID).Str("region", nodegroup.Region).Str("cluster", nodegroup.ClusterName).Str("nodegroup", nodegroup.NodegroupName).
+				Str("reason", decision.Checks[len(decision.Checks)-1].Reason).Msg("nodegroup update is deferred to a later run")
+		}
+		if decision.Update != nil {
+			readyNodegroups++
+			logWithContext.Info().Str("account", nodegroup.AccountID).Str("region", nodegroup.Region).Str("cluster", nodegroup.ClusterName).Str("nodegroup", nodegroup.NodegroupName).
+				Str("targetRelease", decision.Update.TargetRelease).Msg("nodegroup is ready for update")
+		}
+	}
+
+	err = cache.Save()
+	if err != nil {
+		logWithContext.Warn().Err(err).Msg("unable to save ami cache")
+	}
+
+	if readyNodegroups == 0 {
+		logWithContext.Info().Msg("no nodegroups are ready for ami update")
+	}
+
+	return decisions, discoveryFindings.errors, discoveryFindings.skippedRegions, nil
+}
+
+func describeNodegroups(options Options, accounts map[string]aws.Clients, discoveryFindings *findings, ctx context.Context) ([]describedNodegroup, error) {
+	var describedNodegroups []describedNodegroup
+
+	// one limiter bounds the api calls of every discovery level, as regions list their clusters in parallel
+	limiter := utils.NewLimiter(options.DiscoveryConcurrency)
+	nodegroups, err := getNodegroupsToCheck(options, accounts, limiter, discoveryFindings, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	descriptions, errs := utils.MapContext(nodegroups, limiter, discoveryFindings.strict, func(nodegroup aws.NodeGroup, ctx context.Context) (eks.DescribeNodegroupOutput, error) {
+		accountClients, err := getAccountClients(accounts, nodegroup.AccountID)
+		if err != nil {
+			return eks.DescribeNodegroupOutput{}, err
+		}
+
+		awsEks, err := accountClients.Eks(nodegroup.Region)
+		if err != nil {
+			return eks.DescribeNodegroupOutput{}, err
+		}
+
+		return aws.GetNodegroupDescription(nodegroup, awsEks, accountContext(nodegroup.AccountID, ctx))
+	}, ctx)
+
+	for i, nodegroup := range nodegroups {
+		if errs[i] != nil {
+			if err := discoveryFindings.record(nodegroup, PhaseDiscovery, errs[i], ctx); err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+		// amis are resolved with the clients of the nodegroup's account, which can reach its region and partition
+		describedNodegroups = append(describedNodegroups, describedNodegroup{nodegroup: nodegroup, description: descriptions[i], clients: accounts[nodegroup.AccountID]})
+	}
+
+	return describedNodegroups, nil
+}
+
+func getNodegroupsToCheck(options Options, accounts map[string]aws.Clients, limiter *utils.Limiter, discoveryFindings *findings, ctx context.Context) ([]aws.NodeGroup, error) {
 	var nodegroupsToUpdateAmi []aws.NodeGroup
-	var nodegroupsFromRegion []aws.NodeGroup
-	var nodegroupsReadyForAmiUpdate []aws.NodeGroup
-	var regions []string
-	var isOldEnough bool
-	var nodegroupHasTag bool
 	var regionIsAllowed bool
 
-	logWithContext := log.Ctx(ctx).With().Str("function", "GetNodeGroupsToUpdateAmi").Logger()
+	regionsVar, nodegroupsVar := options.Regions, options.Nodegroups
+
+	logWithContext := log.Ctx(ctx).With().Str("function", "getNodegroupsToCheck").Logger()
 
 	if len(nodegroupsVar) > 0 {
 		for _, s := range nodegroupsVar {
-			nodegroupSplit := strings.Split(s, ":")
+			nodegroup, err := parseNodegroup(s, accounts)
+			if err != nil {
+				logWithContext.Warn().Err(err).Strs("nodegrpoupsVar", nodegroupsVar).Msg("skip nodegroup with invalid format")
+
+				continue
+			}
 			regionIsAllowed = true
 			if len(regionsVar) > 0 {
-				regionIsAllowed = utils.Contains(regionsVar, nodegroupSplit[0])
+				regionIsAllowed = utils.Contains(regionsVar, nodegroup.Region)
+			}
+			if utils.Contains(options.ExcludeRegions, nodegroup.Region) {
+				regionIsAllowed = false
 			}
 			if regionIsAllowed {
-				nodegroupsToUpdateAmi = append(nodegroupsToUpdateAmi, aws.NodeGroup{Region: nodegroupSplit[0], ClusterName: nodegroupSplit[1], NodegroupName: nodegroupSplit[2]})
-				logWithContext.Debug().Str("region", nodegroupSplit[0]).Str("cluster", nodegroupSplit[2]).Str("nodegroup", nodegroupSplit[1]).Strs("nodegrpoupsVar", nodegroupsVar).Strs("regionsVar", regionsVar).Msg("add nodegroup to the ami upgrade nodegroups checking list")
+				nodegroupsToUpdateAmi = append(nodegroupsToUpdateAmi, nodegroup)
+				logWithContext.Debug().Str("account", nodegroup.AccountID).Str("region", nodegroup.Region).Str("cluster", nodegroup.ClusterName).Str("nodegroup", nodegroup.NodegroupName).Strs("nodegrpoupsVar", nodegroupsVar).Strs("regionsVar", regionsVar).Msg("add nodegroup to the ami upgrade nodegroups checking list")
 			} else {
-				logWithContext.Debug().Str("region", nodegroupSplit[0]).Str("cluster", nodegroupSplit[2]).Str("nodegroup", nodegroupSplit[1]).Strs("nodegrpoupsVar", nodegroupsVar).Strs("regionsVar", regionsVar).Msg("nodegroup is not in the regions defined by 'regions' flag")
+				logWithContext.Debug().Str("account", nodegroup.AccountID).Str("region", nodegroup.Region).Str("cluster", nodegroup.ClusterName).Str("nodegroup", nodegroup.NodegroupName).Strs("nodegrpoupsVar", nodegroupsVar).Strs("regionsVar", regionsVar).Msg("nodegroup is not in the regions defined by 'regions' flag or is in the regions defined by 'exclude-regions' flag")
 			}
 		}
 	} else {
-		svcEc2, err := aws.Ec2ClientSetup()
-		if err != nil {
-			return nil, err
+		for _, accountID := range sortedAccounts(accounts) {
+			nodegroups, err := getNodegroupsFromRegions(options, accountID, accounts[accountID], limiter, discoveryFindings, accountContext(accountID, ctx))
+			if err != nil {
+				return nil, err
+			}
+			nodegroupsToUpdateAmi = append(nodegroupsToUpdateAmi, nodegroups...)
 		}
-		awsEc2 := aws.RealEc2{Svc: svcEc2}
+	}
+
+	return nodegroupsToUpdateAmi, nil
+}
 
-		regions, err = aws.GetRegionsToCheck(regionsVar, awsEc2, ctx)
+func getNodegroupsFromRegions(options Options, accountID string, clients aws.Clients, limiter *utils.Limiter, discoveryFindings *findings, ctx context.Context) ([]aws.NodeGroup, error) {
+	var nodegroupsFromRegions []aws.NodeGroup
+
+	logWithContext := log.Ctx(ctx).With().Str("function", "getNodegroupsFromRegions").Logger()
+
+	awsEc2, err := clients.Ec2(clients.DefaultRegion())
+	if err != nil {
+		return nil, NewNodegroupError(aws.NodeGroup{AccountID: accountID}, PhaseDiscovery, err)
+	}
+
+	regions, err := aws.GetRegionsToCheck(options.Regions, options.ExcludeRegions, aws.PartitionForRegion(clients.DefaultRegion()), awsEc2, ctx)
+	if err != nil {
+		return nil, NewNodegroupError(aws.NodeGroup{AccountID: accountID}, PhaseDiscovery, err)
+	}
+
+	// regions are not limited themselves, their api calls take slots of the shared limiter
+	results, errs := utils.MapContext(regions, nil, discoveryFindings.strict, func(region string, ctx context.Context) (regionNodegroups, error) {
+		awsEks, err := clients.Eks(region)
 		if err != nil {
-			return nil, err
+			return regionNodegroups{}, err
 		}
 
-		for _, region := range regions {
-			nodegroupsFromRegion, err = aws.GetNodegroupsFromRegion(region, ctx)
-			if err != nil {
+		nodegroups, clusterErrors, err := aws.GetNodegroupsFromRegion(region, awsEks, limiter, discoveryFindings.strict, ctx)
+		if aws.IsRegionUnavailable(err) {
+			return regionNodegroups{unavailable: err}, nil
+		}
+
+		return regionNodegroups{nodegroups: nodegroups, clusterErrors: clusterErrors}, err
+	}, ctx)
+
+	for i, region := range regions {
+		if results[i].unavailable != nil {
+			discoveryFindings.skipRegion(accountID, region, results[i].unavailable, ctx)
+
+			continue
+		}
+		if errs[i] != nil {
+			scope := aws.NodeGroup{AccountID: accountID, Region: region}
+			var clusterError *aws.ClusterError
+			if errors.As(errs[i], &clusterError) {
+				scope.ClusterName = clusterError.Cluster
+			}
+			if err = discoveryFindings.record(scope, PhaseDiscovery, errs[i], ctx); err != nil {
 				return nil, err
 			}
-			nodegroupsToUpdateAmi = append(nodegroupsToUpdateAmi, nodegroupsFromRegion...)
-			for _, nodegroup := range nodegroupsFromRegion {
-				logWithContext.Debug().Str("region", nodegroup.Region).Str("cluster", nodegroup.ClusterName).Str("nodegroup", nodegroup.NodegroupName).Strs("regionsVar", regionsVar).Msg("add nodegroup to the ami upgrade nodegroups checking list")
+
+			continue
+		}
+		for _, clusterError := range results[i].clusterErrors {
+			if err = discoveryFindings.record(aws.NodeGroup{AccountID: accountID, Region: clusterError.Region, ClusterName: clusterError.Cluster}, PhaseDiscovery, clusterError.Err, ctx); err != nil {
+				return nil, err
 			}
 		}
+		for _, nodegroup := range results[i].nodegroups {
+			nodegroup.AccountID = accountID
+			nodegroupsFromRegions = append(nodegroupsFromRegions, nodegroup)
+			logWithContext.Debug().Str("region", nodegroup.Region).Str("cluster", nodegroup.ClusterName).Str("nodegroup", nodegroup.NodegroupName).Strs("regionsVar", options.Regions).Msg("add nodegroup to the ami upgrade nodegroups checking list")
+		}
 	}
 
-	for _, nodegroup := range nodegroupsToUpdateAmi {
-		svcEks, err := aws.EksClientSetup(nodegroup.Region)
-		if err != nil {
-			return nil, err
-		}
-		awsEks := aws.RealEks{Svc: svcEks}
+	return nodegroupsFromRegions, nil
+}
 
-		svcSsm, err := aws.SsmClientSetup(nodegroup.Region)
-		if err != nil {
-			return nil, err
-		}
-		awsSsm := aws.RealSsm{Svc: (svcSsm)}
+func getAccounts(options Options, clients aws.Clients, accountFindings *findings, ctx context.Context) (map[string]aws.Clients, error) {
+	logWithContext := log.Ctx(ctx).With().Str("function", "getAccounts").Logger()
+
+	roleArns, err := getRoleArns(options, clients, ctx)
+	if err != nil {
+		return nil, err
+	}
 
-		svcEc2, err := aws.Ec2ClientSetup()
+	if len(roleArns) == 0 && !options.Organization {
+		accountID, err := clients.AccountID(ctx)
 		if err != nil {
-			return nil, err
+			logWithContext.Warn().Err(err).Msg("unable to get the current account id, it will be missing from logs and reports")
 		}
-		awsEc2 := aws.RealEc2{Svc: svcEc2}
 
-		nodegroupDescription, err := aws.GetNodegroupDescription(nodegroup, awsEks, ctx)
+		return map[string]aws.Clients{accountID: clients}, nil
+	}
+
+	return assumeRoles(roleArns, options.ExternalID, clients, accountFindings, ctx)
+}
+
+func getRoleArns(options Options, clients aws.Clients, ctx context.Context) ([]string, error) {
+	roleArns := slices.Clone(options.RoleArns)
+	if options.Organization {
+		organizationRoleArns, err := getOrganizationRoleArns(options, clients, ctx)
 		if err != nil {
 			return nil, err
 		}
-
-		nodegroupHasTag = true
-		if tagVar != "" {
-			nodegroupHasTag, err = aws.HasNodegroupTag(tagVar, nodegroupDescription.Nodegroup.Tags, ctx)
-			if err != nil {
-				return nil, err
-			}
-			if !nodegroupHasTag {
-				logWithContext.Debug().Str("region", nodegroup.Region).Str("cluster", nodegroup.ClusterName).Str("nodegroup", nodegroup.NodegroupName).Msgf("skip ami update for this nodegroup ('%s' tag is not exist)", tagVar)
+		for _, roleArn := range organizationRoleArns {
+			if !utils.Contains(roleArns, roleArn) {
+				roleArns = append(roleArns, roleArn)
 			}
 		}
+	}
+
+	return roleArns, nil
+}
+
+func assumeRoles(roleArns []string, externalID string, clients aws.Clients, accountFindings *findings, ctx context.Context) (map[string]aws.Clients, error) {
+	logWithContext := log.Ctx(ctx).With().Str("function", "assumeRoles").Logger()
 
-		isTheSameAmiVersion, err := aws.IsTheSameAmiVersion(nodegroup, *nodegroupDescription.Nodegroup.AmiType, *nodegroupDescription.Nodegroup.Version, *nodegroupDescription.Nodegroup.ReleaseVersion, awsSsm, awsEc2, ctx)
+	partition := aws.PartitionForRegion(clients.DefaultRegion())
+	accounts := map[string]aws.Clients{}
+	for _, roleArn := range roleArns {
+		var accountClients aws.Clients
+		accountID, err := partition.RoleArnAccountID(roleArn)
+		if err == nil {
+			accountClients, err = clients.AssumeRole(roleArn, externalID, ctx)
+		}
 		if err != nil {
-			return nil, err
+			if err = accountFindings.record(aws.NodeGroup{AccountID: accountID}, PhaseDiscovery, err, ctx); err != nil {
+				return nil, err
+			}
+
+			continue
 		}
-		if isTheSameAmiVersion {
-			logWithContext.Debug().Str("region", nodegroup.Region).Str("cluster", nodegroup.ClusterName).Str("nodegroup", nodegroup.NodegroupName).Msg("skip ami update for this nodegroup (the newest ami is already in use)")
+		accounts[accountID] = accountClients
+		logWithContext.Info().Str("account", accountID).Str("roleArn", roleArn).Msg("account will be checked for nodegroups to update")
+	}
+
+	return accounts, nil
+}
+
+func getOrganizationRoleArns(options Options, clients aws.Clients, ctx context.Context) ([]string, error) {
+	logWithContext := log.Ctx(ctx).With().Str("function", "getOrganizationRoleArns").Logger()
+
+	partition := aws.PartitionForRegion(clients.DefaultRegion())
+
+	// the template is checked with a placeholder account before listing the accounts
+	_, err := aws.RoleArnsFromTemplate(options.RoleArnTemplate, partition, []string{"000000000000"})
+	if err != nil {
+		return nil, err
+	}
+
+	awsOrganizations, err := clients.Organizations()
+	if err != nil {
+		return nil, NewNodegroupError(aws.NodeGroup{}, PhaseDiscovery, err)
+	}
+
+	accountIDs, err := aws.GetOrganizationAccounts(options.OrganizationUnits, options.OrganizationTag, awsOrganizations, ctx)
+	if err != nil {
+		return nil, NewNodegroupError(aws.NodeGroup{}, PhaseDiscovery, err)
+	}
+	if len(accountIDs) == 0 {
+		logWithContext.Warn().Strs("organizationUnits", options.OrganizationUnits).Str("organizationTag", options.OrganizationTag).Msg("no active organization accounts have been selected")
+	}
+
+	return aws.RoleArnsFromTemplate(options.RoleArnTemplate, partition, accountIDs)
+}
+
+func getAccountClients(accounts map[string]aws.Clients, accountID string) (aws.Clients, error) {
+	clients, ok := accounts[accountID]
+	if !ok {
+		return nil, fmt.Errorf("account (%s) is not one of the '--role-arns' accounts or its role could not be assumed", accountID)
+	}
+
+	return clients, nil
+}
+
+func sortedAccounts(accounts map[string]aws.Clients) []string {
+	accountIDs := make([]string, 0, len(accounts))
+	for accountID := range accounts {
+		accountIDs = append(accountIDs, accountID)
+	}
+	sort.Strings(accountIDs)
+
+	return accountIDs
+}
+
+func accountContext(accountID string, ctx context.Context) context.Context {
+	if accountID == "" {
+		return ctx
+	}
+
+	return log.Ctx(ctx).With().Str("account", accountID).Logger().WithContext(ctx)
+}
+
+func parseNodegroup(s string, accounts map[string]aws.Clients) (aws.NodeGroup, error) {
+	nodegroupSplit := strings.Split(s, ":")
+	switch len(nodegroupSplit) {
+	case 3:
+		if len(accounts) != 1 {
+			return aws.NodeGroup{}, fmt.Errorf("nodegroup (%s) has to be prefixed with the account id when more than one account is checked", s)
 		}
+		accountIDs := sortedAccounts(accounts)
 
-		isOldEnough = true
-		if skipNewerThanDays > 0 && nodegroupHasTag {
-			today := time.Now()
-			isOldEnough, err = aws.IsLastAmiOldEnough(skipNewerThanDays, nodegroup, today, *nodegroupDescription.Nodegroup.AmiType, *nodegroupDescription.Nodegroup.Version, awsSsm, awsEc2, ctx)
-			if err != nil {
-				return nil, err
-			}
-			if !isOldEnough {
-				logWithContext.Debug().Str("region", nodegroup.Region).Str("cluster", nodegroup.ClusterName).Str("nodegroup", nodegroup.NodegroupName).Msg("skip ami update for this nodegroup (latest available ami for this nodegroup is too new)")
-			}
+		return aws.NodeGroup{AccountID: accountIDs[0], Region: nodegroupSplit[0], ClusterName: nodegroupSplit[1], NodegroupName: nodegroupSplit[2]}, nil
+	case 4:
+		return aws.NodeGroup{AccountID: nodegroupSplit[0], Region: nodegroupSplit[1], ClusterName: nodegroupSplit[2], NodegroupName: nodegroupSplit[3]}, nil
+	default:
+		return aws.NodeGroup{}, fmt.Errorf("nodegroup (%s) is not in the 'region:cluster:nodegroup' or 'account:region:cluster:nodegroup' format", s)
+	}
+}
+
+func loadAmiCache(options Options, ctx context.Context) *aws.AmiCache {
+	logWithContext := log.Ctx(ctx).With().Str("function", "loadAmiCache").Logger()
+
+	if options.CacheFile == "" || options.NoCache {
+		return nil
+	}
+
+	cache, err := aws.LoadAmiCache(options.CacheFile, options.CacheTTL)
+	if err != nil {
+		logWithContext.Warn().Str("cacheFile", options.CacheFile).Err(err).Msg("unable to load ami cache, starting with an empty one")
+	}
+
+	return cache
+}
+
+func prefetchAmiCatalog(describedNodegroups []describedNodegroup, resolver *aws.AmiResolver, concurrency int, ctx context.Context) {
+	var regions []string
+	var keys []aws.AmiKey
+	regionClients := map[string]aws.Clients{}
+
+	logWithContext := log.Ctx(ctx).With().Str("function", "prefetchAmiCatalog").Logger()
+
+	for _, described := range describedNodegroups {
+		// any account with nodegroups in the region can read its catalog
+		if !utils.Contains(regions, described.nodegroup.Region) {
+			regions = append(regions, described.nodegroup.Region)
+			regionClients[described.nodegroup.Region] = described.clients
 		}
+		keys = append(keys, amiKey(described.nodegroup, described.description))
+	}
+
+	_, errs := utils.Map(regions, concurrency, func(region string) (struct{}, error) {
+		return struct{}{}, resolver.Prefetch(region, keys, regionClients[region], ctx)
+	})
 
-		if isOldEnough && nodegroupHasTag && !isTheSameAmiVersion {
-			nodegroupsReadyForAmiUpdate = append(nodegroupsReadyForAmiUpdate, nodegroup)
-			logWithContext.Info().Str("region", nodegroup.Region).Str("cluster", nodegroup.ClusterName).Str("nodegroup", nodegroup.NodegroupName).Msg("nodegroup is ready for update")
+	for i, region := range regions {
+		if errs[i] != nil {
+			logWithContext.Warn().Str("region", region).Str("code", aws.ErrorCode(errs[i])).Err(errs[i]).Msg("unable to prefetch ami catalog, amis will be resolved separately")
 		}
 	}
+}
 
-	if len(nodegroupsReadyForAmiUpdate) == 0 {
-		logWithContext.Info().Msg("no nodegroups are ready for ami update")
+func amiKey(nodegroup aws.NodeGroup, nodegroupDescription eks.DescribeNodegroupOutput) aws.AmiKey {
+	return aws.AmiKey{Region: nodegroup.Region, AmiType: string(nodegroupDescription.Nodegroup.AmiType), Version: *nodegroupDescription.Nodegroup.Version}
+}
+
+func newUpdate(described describedNodegroup, resolver *aws.AmiResolver, ctx context.Context) (*Update, error) {
+	nodegroup := described.description.Nodegroup
+	update := &Update{
+		Nodegroup:      described.nodegroup,
+		AmiType:        string(nodegroup.AmiType),
+		Version:        *nodegroup.Version,
+		CurrentRelease: *nodegroup.ReleaseVersion,
+		TargetRelease:  described.policy.release,
+		ReleaseVersion: described.policy.release,
+		Reason:         "pinned release is not in use",
+		policy:         described.policy,
+	}
+	if described.policy.release != config.ReleaseLatest {
+		return update, nil
 	}
 
-	return nodegroupsReadyForAmiUpdate, nil
+	// the resolver keeps the ami found by the readiness check, so it is not looked up again
+	latestAmi, err := resolver.Resolve(amiKey(described.nodegroup, described.description), described.clients, ctx)
+	if err != nil {
+		return nil, err
+	}
+	update.TargetRelease, update.ReleaseVersion, update.AmiID = latestAmi.Release, latestAmi.ReleaseVersion, latestAmi.ImageID
+	update.Reason = fmt.Sprintf("newer ami is available (published %s)", latestAmi.PublishDate.UTC().Format(time.DateOnly))
+	if described.policy.skipNewerThanDays > 0 {
+		update.Reason = fmt.Sprintf("newer ami is available (published %s, more than %d days ago)", latestAmi.PublishDate.UTC().Format(time.DateOnly), described.policy.skipNewerThanDays)
+	}
+
+	return update, nil
+}
+
+func resolvePolicy(nodegroup aws.NodeGroup, options Options, policies *config.Config) nodegroupPolicy {
+	resolution := policies.Resolve(nodegroup, config.Policy{
+		SkipNewerThanDays: &options.SkipNewerThanDays,
+		Release:           config.ReleaseLatest,
+		Force:             new(bool),
+	})
+	policy := nodegroupPolicy{
+		skipNewerThanDays: *resolution.Policy.SkipNewerThanDays,
+		release:           resolution.Policy.Release,
+		force:             *resolution.Policy.Force,
+		concurrencyScope:  resolution.ConcurrencyScope,
+		blocks:            resolution.Blocks,
+	}
+	if resolution.Policy.Concurrency != nil {
+		policy.concurrency = *resolution.Policy.Concurrency
+	}
+
+	return policy
+}
+
+func isPinnedReleaseMissing(nodegroup aws.NodeGroup, nodegroupDescription eks.DescribeNodegroupOutput, release string, ctx context.Context) (bool, error) {
+	logWithContext := log.Ctx(ctx).With().Str("function", "isPinnedReleaseMissing").Logger()
+
+	// bottlerocket release versions follow the os version instead of the kubernetes one
+	version := *nodegroupDescription.Nodegroup.Version
+	if !strings.HasPrefix(string(nodegroupDescription.Nodegroup.AmiType), "BOTTLEROCKET") && !strings.HasPrefix(release, version+".") {
+		return false, fmt.Errorf("pinned release (%s) is not for the nodegroup's kubernetes version (%s)", release, version)
+	}
+	if *nodegroupDescription.Nodegroup.ReleaseVersion == release {
+		logWithContext.Debug().Str("region", nodegroup.Region).Str("cluster", nodegroup.ClusterName).Str("nodegroup", nodegroup.NodegroupName).Str("release", release).Msg("skip ami update for this nodegroup (the pinned release is already in use)")
+
+		return false, nil
+	}
+
+	return true, nil
 }
 
-func UpdateAmi(dryrun bool, skipNewerThanDays uint, regionsVar, nodegroupsVar []string, tagVar string, ctx context.Context) error {
-	var errorGroup errgroup.Group
+func updateNodegroup(update Update, options Options, clients aws.Clients, inFlight *inFlightUpdates, ctx context.Context) (Phase, error) {
+	nodegroup := update.Nodegroup
+	err := canStartUpdate(options.UpdateStartCutoff, time.Now(), ctx)
+	if err != nil {
+		return PhaseUpdate, err
+	}
+
+	if options.Dryrun {
+		log.Ctx(ctx).Info().Str("account", nodegroup.AccountID).Str("region", nodegroup.Region).Str("cluster", nodegroup.ClusterName).Str("nodegroup", nodegroup.NodegroupName).Msg("drying is true. exiting")
+
+		return PhaseUpdate, nil
+	}
+
+	awsEks, err := clients.Eks(nodegroup.Region)
+	if err != nil {
+		return PhaseUpdate, err
+	}
 
-	nodegroups, err := GetNodeGroupsToUpdateAmi(skipNewerThanDays, regionsVar, nodegroupsVar, tagVar, ctx)
+	// an empty release version, e.g. of windows amis, lets eks pick the latest one
+	err = aws.StartAmiUpdate(nodegroup, update.ReleaseVersion, update.policy.force, awsEks, ctx)
 	if err != nil {
-		return err
+		return PhaseUpdate, err
 	}
+	inFlight.start(nodegroup)
 
-	for _, nodegroup := range nodegroups {
-		errorGroup.Go(func() error {
-			return aws.AmiUpdate(nodegroup.Region, nodegroup.ClusterName, nodegroup.NodegroupName, dryrun, ctx)
-		})
+	err = aws.WaitForAmiUpdate(nodegroup, awsEks, aws.WaitOptions{
+		MinDelay: options.UpdateWaitMinDelay,
+		MaxDelay: options.UpdateWaitMaxDelay,
+		Timeout:  options.UpdateWaitTimeout,
+	}, ctx)
+	if err == nil || ctx.Err() == nil {
+		inFlight.finish(nodegroup)
+	}
+	if err != nil {
+		return PhaseWait, err
 	}
 
-	return errors.Wrap(errorGroup.Wait(), "at least one nodegroup can not be updated")
+	return PhaseWait, nil
 }
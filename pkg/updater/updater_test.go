@@ -0,0 +1,618 @@
+package updater
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	awsLib "github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eks"
+	eksTypes "github.com/aws/aws-sdk-go-v2/service/eks/types"
+	organizationsTypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/smithy-go"
+	"github.com/loomhq/eks-ng-ami-updater/pkg/aws"
+	"github.com/loomhq/eks-ng-ami-updater/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateNodegroup(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		dryrun          bool
+		errUpdate       error
+		errWait         error
+		expectedPhase   Phase
+		expectedError   error
+		expectedUpdates int
+	}{
+		{name: "nodegroup is updated",
+			expectedPhase:   PhaseWait,
+			expectedError:   nil,
+			expectedUpdates: 1,
+		},
+		{name: "dryrun does not start the update",
+			dryrun:          true,
+			expectedPhase:   PhaseUpdate,
+			expectedError:   nil,
+			expectedUpdates: 0,
+		},
+		{name: "update can not be started",
+			errUpdate:       &smithy.GenericAPIError{Code: "ResourceInUseException", Message: "update already in progress"},
+			expectedPhase:   PhaseUpdate,
+			expectedError:   &smithy.GenericAPIError{Code: "ResourceInUseException", Message: "update already in progress"},
+			expectedUpdates: 1,
+		},
+		{name: "nodegroup can not be described while waiting",
+			errWait:         &smithy.GenericAPIError{Code: "ResourceNotFoundException", Message: "no node group found"},
+			expectedPhase:   PhaseWait,
+			expectedError:   &smithy.GenericAPIError{Code: "ResourceNotFoundException", Message: "no node group found"},
+			expectedUpdates: 1,
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		var updates atomic.Int32
+		clients := aws.TestClients{AwsEks: aws.TestEks{ErrUpdateNodegroup: test.errUpdate, ErrDescribeNodegroup: test.errWait, UpdateCalls: &updates}}
+		nodegroup := aws.NodeGroup{Region: "eu-west-1", ClusterName: "cluster-1", NodegroupName: "ng-1"}
+
+		phase, err := updateNodegroup(Update{Nodegroup: nodegroup, policy: nodegroupPolicy{release: config.ReleaseLatest}}, Options{Dryrun: test.dryrun, UpdateWaitTimeout: time.Minute}, clients, newInFlightUpdates(), context.Background())
+
+		assert.Equal(t, test.expectedPhase, phase)
+		assert.Equal(t, test.expectedError, err)
+		assert.Equal(t, test.expectedUpdates, int(updates.Load()))
+	}
+}
+
+func TestUpdateNodegroupReleaseVersion(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		update        Update
+		expectedValue *string
+	}{
+		{name: "reviewed release version is pinned",
+			update:        Update{TargetRelease: "v20240202", ReleaseVersion: "1.28.5-20240202", policy: nodegroupPolicy{release: config.ReleaseLatest}},
+			expectedValue: awsLib.String("1.28.5-20240202"),
+		},
+		{name: "eks picks the latest ami without a release version",
+			update:        Update{TargetRelease: "2024.02.13", policy: nodegroupPolicy{release: config.ReleaseLatest}},
+			expectedValue: nil,
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		var input eks.UpdateNodegroupVersionInput
+		test.update.Nodegroup = aws.NodeGroup{Region: "eu-west-1", ClusterName: "cluster-1", NodegroupName: "ng-1"}
+		clients := aws.TestClients{AwsEks: aws.TestEks{InputUpdateNodegroup: &input}}
+
+		_, err := updateNodegroup(test.update, Options{UpdateWaitTimeout: time.Minute}, clients, newInFlightUpdates(), context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, test.expectedValue, input.ReleaseVersion)
+	}
+}
+
+func TestUpdateNodegroupInterrupted(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name             string
+		timeout          time.Duration
+		cutoff           time.Duration
+		canceled         bool
+		interruptWait    bool
+		expectedPhase    Phase
+		expectedError    error
+		expectedUpdates  int
+		expectedInFlight int
+	}{
+		{name: "update starts when enough time is left",
+			timeout:          time.Hour,
+			cutoff:           10 * time.Minute,
+			expectedPhase:    PhaseWait,
+			expectedError:    nil,
+			expectedUpdates:  1,
+			expectedInFlight: 0,
+		},
+		{name: "update does not start near the deadline",
+			timeout:          5 * time.Minute,
+			cutoff:           10 * time.Minute,
+			expectedPhase:    PhaseUpdate,
+			expectedError:    ErrRunDeadline,
+			expectedUpdates:  0,
+			expectedInFlight: 0,
+		},
+		{name: "update does not start after the run is canceled",
+			canceled:         true,
+			expectedPhase:    PhaseUpdate,
+			expectedError:    context.Canceled,
+			expectedUpdates:  0,
+			expectedInFlight: 0,
+		},
+		{name: "interrupted wait leaves the update in flight",
+			interruptWait:    true,
+			expectedPhase:    PhaseWait,
+			expectedError:    context.Canceled,
+			expectedUpdates:  1,
+			expectedInFlight: 1,
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		ctx, cancel := context.WithCancel(context.Background())
+		if test.timeout > 0 {
+			ctx, cancel = context.WithTimeout(context.Background(), test.timeout)
+		}
+		if test.canceled {
+			cancel()
+		}
+
+		var updates atomic.Int32
+		awsEks := aws.TestEks{UpdateCalls: &updates}
+		if test.interruptWait {
+			awsEks.Interrupt = cancel
+		}
+		inFlight := newInFlightUpdates()
+		nodegroup := aws.NodeGroup{Region: "eu-west-1", ClusterName: "cluster-1", NodegroupName: "ng-1"}
+
+		phase, err := updateNodegroup(Update{Nodegroup: nodegroup, policy: nodegroupPolicy{release: config.ReleaseLatest}}, Options{UpdateStartCutoff: test.cutoff, UpdateWaitTimeout: time.Minute}, aws.TestClients{AwsEks: awsEks}, inFlight, ctx)
+
+		assert.Equal(t, test.expectedPhase, phase)
+		assert.ErrorIs(t, err, test.expectedError)
+		assert.Equal(t, test.expectedUpdates, int(updates.Load()))
+		assert.Len(t, inFlight.report(ctx), test.expectedInFlight)
+		cancel()
+	}
+}
+
+func TestPlanApply(t *testing.T) {
+	t.Parallel()
+
+	errUpdate := &smithy.GenericAPIError{Code: "ResourceInUseException", Message: "update already in progress"}
+	findingError := NewNodegroupError(aws.NodeGroup{Region: "us-west-2"}, PhaseDiscovery, errors.New("access denied"))
+
+	tests := []struct {
+		name            string
+		errUpdate       error
+		errors          []*NodegroupError
+		dryrun          bool
+		expectedResult  UpdateResult
+		expectedErrors  int
+		expectedHistory int
+	}{
+		{name: "updates are applied and recorded",
+			expectedResult:  UpdateResultUpdated,
+			expectedErrors:  0,
+			expectedHistory: 1,
+		},
+		{name: "failed update and plan errors are returned and recorded",
+			errUpdate:       errUpdate,
+			errors:          []*NodegroupError{findingError},
+			expectedResult:  UpdateResultFailed,
+			expectedErrors:  2,
+			expectedHistory: 1,
+		},
+		{name: "dryrun is not recorded",
+			dryrun:          true,
+			expectedErrors:  0,
+			expectedHistory: 0,
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		historyFile := filepath.Join(t.TempDir(), "history.jsonl")
+		nodegroup := aws.NodeGroup{AccountID: "111111111111", Region: "eu-west-1", ClusterName: "cluster-1", NodegroupName: "ng-1"}
+		plan := &Plan{
+			Updates:  []Update{{Nodegroup: nodegroup, policy: nodegroupPolicy{release: config.ReleaseLatest}}},
+			Errors:   test.errors,
+			accounts: map[string]aws.Clients{"111111111111": aws.TestClients{AwsEks: aws.TestEks{ErrUpdateNodegroup: test.errUpdate}}},
+		}
+
+		err := plan.Apply(Options{Dryrun: test.dryrun, HistoryFile: historyFile, UpdateWaitTimeout: time.Minute}, context.Background())
+
+		var updateErrors *MultiError
+		if test.expectedErrors > 0 {
+			assert.ErrorAs(t, err, &updateErrors)
+			assert.Len(t, updateErrors.Errors, test.expectedErrors)
+		} else {
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, test.errors, plan.Errors)
+
+		records, err := LoadHistory(historyFile, 0)
+		assert.NoError(t, err)
+		assert.Len(t, records, test.expectedHistory)
+		if test.expectedHistory > 0 {
+			assert.Equal(t, "apply", records[0].Command)
+			assert.Equal(t, test.expectedErrors, records[0].Failures)
+			assert.Equal(t, test.expectedResult, records[0].Updates[0].Result)
+		}
+	}
+}
+
+func TestPlanApplyUpdateFirst(t *testing.T) {
+	t.Parallel()
+
+	var mutex sync.Mutex
+	var started []string
+	awsEks := aws.TestEks{Latency: 10 * time.Millisecond, OnUpdateNodegroup: func(input *eks.UpdateNodegroupVersionInput) {
+		mutex.Lock()
+		started = append(started, *input.NodegroupName)
+		mutex.Unlock()
+	}}
+	update := func(name string, first bool) Update {
+		return Update{Nodegroup: aws.NodeGroup{AccountID: "111111111111", Region: "eu-west-1", ClusterName: "cluster-1", NodegroupName: name}, First: first,
+			policy: nodegroupPolicy{release: config.ReleaseLatest}}
+	}
+	plan := &Plan{
+		Updates:  []Update{update("ng-1", false), update("ng-2", true), update("ng-3", false), update("ng-4", true)},
+		accounts: map[string]aws.Clients{"111111111111": aws.TestClients{AwsEks: awsEks}},
+	}
+
+	err := plan.Apply(Options{UpdateWaitTimeout: time.Minute}, context.Background())
+
+	assert.NoError(t, err)
+	assert.Len(t, started, 4)
+	assert.ElementsMatch(t, []string{"ng-2", "ng-4"}, started[:2])
+	assert.ElementsMatch(t, []string{"ng-1", "ng-3"}, started[2:])
+}
+
+func TestPrintPlan(t *testing.T) {
+	t.Parallel()
+
+	var output bytes.Buffer
+
+	err := PrintPlan(&output, &Plan{SkippedRegions: []SkippedRegion{{AccountID: "111111111111", Region: "me-south-1", Code: "AccessDeniedException"}}})
+
+	assert.NoError(t, err)
+	lines := strings.Split(output.String(), "\n")
+	assert.Equal(t, "no nodegroups are ready for ami update", lines[0])
+	assert.Equal(t, "skipped regions (eks calls are denied or the region is not enabled):", lines[2])
+	assert.Equal(t, "111111111111 me-south-1 AccessDeniedException", strings.Join(strings.Fields(lines[3]), " "))
+}
+
+func TestResolvePolicy(t *testing.T) {
+	t.Parallel()
+
+	policies := &config.Config{
+		Overrides: []config.Override{
+			{Name: "production", Match: config.Match{Cluster: "prod-*"}, Policy: config.Policy{SkipNewerThanDays: toPtr(uint(7)), Concurrency: toPtr(1)}},
+			{Match: config.Match{Nodegroup: "*-gpu"}, Policy: config.Policy{Release: "1.29.0-20240202", Force: toPtr(true)}},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		nodegroup     aws.NodeGroup
+		expectedValue nodegroupPolicy
+	}{
+		{name: "flags are used when no override matches",
+			nodegroup:     aws.NodeGroup{Region: "eu-west-1", ClusterName: "staging-1", NodegroupName: "ng-1"},
+			expectedValue: nodegroupPolicy{skipNewerThanDays: 3, release: config.ReleaseLatest, concurrencyScope: config.DefaultsScope},
+		},
+		{name: "overrides replace flags",
+			nodegroup:     aws.NodeGroup{Region: "eu-west-1", ClusterName: "prod-1", NodegroupName: "ng-gpu"},
+			expectedValue: nodegroupPolicy{skipNewerThanDays: 7, release: "1.29.0-20240202", force: true, concurrency: 1, concurrencyScope: "overrides[0] (production)", blocks: []string{"overrides[0] (production)", "overrides[1]"}},
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+
+		output := resolvePolicy(test.nodegroup, Options{SkipNewerThanDays: 3}, policies)
+
+		assert.Equal(t, test.expectedValue, output)
+	}
+}
+
+func TestIsPinnedReleaseMissing(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		amiType        eksTypes.AMITypes
+		version        string
+		releaseVersion string
+		release        string
+		expectedValue  bool
+		expectedError  bool
+	}{
+		{name: "pinned release is not in use",
+			amiType:        eksTypes.AMITypesAl2X8664,
+			version:        "1.29",
+			releaseVersion: "1.29.0-20240129",
+			release:        "1.29.0-20240202",
+			expectedValue:  true,
+		},
+		{name: "pinned release is already in use",
+			amiType:        eksTypes.AMITypesAl2X8664,
+			version:        "1.29",
+			releaseVersion: "1.29.0-20240202",
+			release:        "1.29.0-20240202",
+			expectedValue:  false,
+		},
+		{name: "pinned release is for another kubernetes version",
+			amiType:        eksTypes.AMITypesAl2X8664,
+			version:        "1.28",
+			releaseVersion: "1.28.5-20240129",
+			release:        "1.29.0-20240202",
+			expectedError:  true,
+		},
+		{name: "bottlerocket release follows the os version",
+			amiType:        eksTypes.AMITypesBottlerocketX8664,
+			version:        "1.29",
+			releaseVersion: "1.18.0-7452c37e",
+			release:        "1.19.1-c325a08b",
+			expectedValue:  true,
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		nodegroup := aws.NodeGroup{Region: "eu-west-1", ClusterName: "cluster-1", NodegroupName: "ng-1"}
+		description := eks.DescribeNodegroupOutput{Nodegroup: &eksTypes.Nodegroup{AmiType: test.amiType, Version: &test.version, ReleaseVersion: &test.releaseVersion}}
+
+		output, err := isPinnedReleaseMissing(nodegroup, description, test.release, context.Background())
+
+		assert.Equal(t, test.expectedValue, output)
+		assert.Equal(t, test.expectedError, err != nil)
+	}
+}
+
+func TestScopeLimits(t *testing.T) {
+	t.Parallel()
+
+	var wg sync.WaitGroup
+	var running, maxRunning atomic.Int32
+	limits := newScopeLimits()
+
+	for range 5 {
+		limit := limits.get("overrides[0] (production)", 2)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _ = limit.run(func() (Phase, error) {
+				current := running.Add(1)
+				for {
+					observed := maxRunning.Load()
+					if current <= observed || maxRunning.CompareAndSwap(observed, current) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				running.Add(-1)
+
+				return PhaseWait, nil
+			}, context.Background())
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(2), maxRunning.Load())
+	assert.Same(t, limits.get("overrides[0] (production)", 2), limits.get("overrides[0] (production)", 2))
+	assert.Nil(t, limits.get(config.DefaultsScope, 0).slots)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	limit := limits.get("overrides[1]", 1)
+	limit.slots <- struct{}{}
+	phase, err := limit.run(func() (Phase, error) { return PhaseWait, nil }, ctx)
+	assert.Equal(t, PhaseUpdate, phase)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestGetAccounts(t *testing.T) {
+	t.Parallel()
+
+	errAccessDenied := &smithy.GenericAPIError{Code: "AccessDenied", Message: "not authorized to perform sts:AssumeRole"}
+
+	tests := []struct {
+		name             string
+		clients          aws.TestClients
+		roleArns         []string
+		organization     bool
+		roleArnTemplate  string
+		strict           bool
+		expectedAccounts []string
+		expectedFindings []string
+		expectedError    bool
+	}{
+		{name: "current account is used without role arns",
+			clients:          aws.TestClients{Account: "111111111111"},
+			expectedAccounts: []string{"111111111111"},
+		},
+		{name: "current account id is unknown",
+			clients:          aws.TestClients{ErrAccountID: errors.New("no credentials")},
+			expectedAccounts: []string{""},
+		},
+		{name: "each role arn is assumed",
+			clients:          aws.TestClients{Account: "111111111111"},
+			roleArns:         []string{"arn:aws:iam::222222222222:role/eks-ng-ami-updater", "arn:aws:iam::333333333333:role/eks-ng-ami-updater"},
+			expectedAccounts: []string{"222222222222", "333333333333"},
+		},
+		{name: "invalid role arn is reported",
+			clients:          aws.TestClients{Account: "111111111111"},
+			roleArns:         []string{"arn:aws:iam::222222222222:role/eks-ng-ami-updater", "eks-ng-ami-updater"},
+			expectedAccounts: []string{"222222222222"},
+			expectedFindings: []string{""},
+		},
+		{name: "role which cannot be assumed is reported",
+			clients:          aws.TestClients{Account: "111111111111", ErrAssumeRole: errAccessDenied},
+			roleArns:         []string{"arn:aws:iam::222222222222:role/eks-ng-ami-updater"},
+			expectedAccounts: []string{},
+			expectedFindings: []string{"222222222222"},
+		},
+		{name: "organization accounts are assumed with the role template",
+			clients:          aws.TestClients{Account: "111111111111", AwsOrganizations: aws.TestOrganizations{Accounts: organizationAccounts("333333333333", "222222222222")}},
+			roleArns:         []string{"arn:aws:iam::222222222222:role/eks-ng-ami-updater"},
+			organization:     true,
+			roleArnTemplate:  "arn:aws:iam::{{.AccountID}}:role/eks-ng-ami-updater",
+			expectedAccounts: []string{"222222222222", "333333333333"},
+		},
+		{name: "organization without accounts",
+			clients:          aws.TestClients{Account: "111111111111", AwsOrganizations: aws.TestOrganizations{}},
+			organization:     true,
+			roleArnTemplate:  "arn:aws:iam::{{.AccountID}}:role/eks-ng-ami-updater",
+			expectedAccounts: []string{},
+		},
+		{name: "organization accounts cannot be listed",
+			clients:         aws.TestClients{Account: "111111111111", AwsOrganizations: aws.TestOrganizations{ErrListAccounts: &smithy.GenericAPIError{Code: "AWSOrganizationsNotInUseException", Message: "not a member of an organization"}}},
+			organization:    true,
+			roleArnTemplate: "arn:aws:iam::{{.AccountID}}:role/eks-ng-ami-updater",
+			expectedError:   true,
+		},
+		{name: "invalid role template stops the run",
+			clients:         aws.TestClients{Account: "111111111111", AwsOrganizations: aws.TestOrganizations{Accounts: organizationAccounts("222222222222")}},
+			organization:    true,
+			roleArnTemplate: "arn:aws:iam::{{.AccountId}}:role/eks-ng-ami-updater",
+			expectedError:   true,
+		},
+		{name: "role which cannot be assumed stops the strict run",
+			clients:       aws.TestClients{Account: "111111111111", ErrAssumeRole: errAccessDenied},
+			roleArns:      []string{"arn:aws:iam::222222222222:role/eks-ng-ami-updater"},
+			strict:        true,
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		accountFindings := findings{strict: test.strict}
+
+		options := Options{RoleArns: test.roleArns, Organization: test.organization, RoleArnTemplate: test.roleArnTemplate}
+
+		accounts, err := getAccounts(options, test.clients, &accountFindings, context.Background())
+
+		assert.Equal(t, test.expectedError, err != nil)
+		if test.expectedError {
+			continue
+		}
+		assert.Equal(t, test.expectedAccounts, sortedAccounts(accounts))
+		var findingAccounts []string
+		for _, finding := range accountFindings.errors {
+			findingAccounts = append(findingAccounts, finding.Account)
+		}
+		assert.Equal(t, test.expectedFindings, findingAccounts)
+	}
+}
+
+func TestGetNodegroupsFromRegions(t *testing.T) {
+	t.Parallel()
+
+	errAccessDenied := &smithy.GenericAPIError{Code: "AccessDeniedException", Message: "not authorized"}
+
+	tests := []struct {
+		name                   string
+		awsEks                 aws.TestEks
+		strict                 bool
+		expectedSkippedRegions int
+		expectedFindings       []string
+		expectedError          bool
+	}{
+		{name: "denied region is skipped",
+			awsEks:                 aws.TestEks{ErrListClusters: errAccessDenied},
+			expectedSkippedRegions: 1,
+		},
+		{name: "denied region is skipped in a strict run",
+			awsEks:                 aws.TestEks{ErrListClusters: errAccessDenied},
+			strict:                 true,
+			expectedSkippedRegions: 1,
+		},
+		{name: "denied cluster is reported",
+			awsEks:           aws.TestEks{OutputListClusters: &eks.ListClustersOutput{Clusters: []string{"cluster-1"}}, ErrListNodegroups: errAccessDenied},
+			expectedFindings: []string{"cluster-1"},
+		},
+		{name: "denied cluster stops a strict run instead of skipping the region",
+			awsEks:        aws.TestEks{OutputListClusters: &eks.ListClustersOutput{Clusters: []string{"cluster-1"}}, ErrListNodegroups: errAccessDenied},
+			strict:        true,
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+		discoveryFindings := findings{strict: test.strict}
+
+		_, err := getNodegroupsFromRegions(Options{Regions: []string{"eu-west-1"}}, "111111111111", aws.TestClients{AwsEks: test.awsEks}, nil, &discoveryFindings, context.Background())
+
+		assert.Equal(t, test.expectedError, err != nil)
+		assert.Len(t, discoveryFindings.skippedRegions, test.expectedSkippedRegions)
+		var findingClusters []string
+		for _, finding := range discoveryFindings.errors {
+			findingClusters = append(findingClusters, finding.Cluster)
+		}
+		assert.Equal(t, test.expectedFindings, findingClusters)
+	}
+}
+
+func TestParseNodegroup(t *testing.T) {
+	t.Parallel()
+
+	oneAccount := map[string]aws.Clients{"111111111111": aws.TestClients{}}
+	twoAccounts := map[string]aws.Clients{"111111111111": aws.TestClients{}, "222222222222": aws.TestClients{}}
+
+	tests := []struct {
+		name          string
+		nodegroup     string
+		accounts      map[string]aws.Clients
+		expectedValue aws.NodeGroup
+		expectedError bool
+	}{
+		{name: "nodegroup without account with a single account",
+			nodegroup:     "eu-west-1:cluster-1:ng-1",
+			accounts:      oneAccount,
+			expectedValue: aws.NodeGroup{AccountID: "111111111111", Region: "eu-west-1", ClusterName: "cluster-1", NodegroupName: "ng-1"},
+		},
+		{name: "nodegroup without account with many accounts",
+			nodegroup:     "eu-west-1:cluster-1:ng-1",
+			accounts:      twoAccounts,
+			expectedError: true,
+		},
+		{name: "nodegroup with account",
+			nodegroup:     "222222222222:eu-west-1:cluster-1:ng-1",
+			accounts:      twoAccounts,
+			expectedValue: aws.NodeGroup{AccountID: "222222222222", Region: "eu-west-1", ClusterName: "cluster-1", NodegroupName: "ng-1"},
+		},
+		{name: "nodegroup in invalid format",
+			nodegroup:     "eu-west-1:cluster-1",
+			accounts:      oneAccount,
+			expectedError: true,
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+
+		output, err := parseNodegroup(test.nodegroup, test.accounts)
+
+		assert.Equal(t, test.expectedValue, output)
+		assert.Equal(t, test.expectedError, err != nil)
+	}
+}
+
+func toPtr[T any](value T) *T {
+	return &value
+}
+
+func organizationAccounts(ids ...string) []organizationsTypes.Account {
+	accounts := make([]organizationsTypes.Account, 0, len(ids))
+	for _, id := range ids {
+		accounts = append(accounts, organizationsTypes.Account{Id: &id, State: organizationsTypes.AccountStateActive})
+	}
+
+	return accounts
+}
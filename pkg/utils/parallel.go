@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/sync/errgroup"
+)
+
+type Limiter struct {
+	slots chan struct{}
+}
+
+func NewLimiter(limit int) *Limiter {
+	if limit < 1 {
+		limit = 1
+	}
+
+	return &Limiter{slots: make(chan struct{}, limit)}
+}
+
+func Limit[R any](limiter *Limiter, fn func() (R, error), ctx context.Context) (R, error) {
+	var zero R
+
+	// a nil limiter does not limit, so callers which fan out other limited calls can share the code
+	if limiter == nil {
+		if ctx.Err() != nil {
+			return zero, ctx.Err()
+		}
+
+		return fn()
+	}
+
+	select {
+	case limiter.slots <- struct{}{}:
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	}
+	defer func() { <-limiter.slots }()
+
+	// select picks randomly when both cases are ready, a cancelled context must not start new calls
+	if ctx.Err() != nil {
+		return zero, ctx.Err()
+	}
+
+	return fn()
+}
+
+func Map[T, R any](items []T, concurrency int, fn func(T) (R, error)) ([]R, []error) {
+	return MapContext(items, NewLimiter(concurrency), false, func(item T, _ context.Context) (R, error) {
+		return fn(item)
+	}, context.Background())
+}
+
+func MapContext[T, R any](items []T, limiter *Limiter, failFast bool, fn func(T, context.Context) (R, error), ctx context.Context) ([]R, []error) {
+	var errorGroup errgroup.Group
+	var once sync.Once
+
+	results := make([]R, len(items))
+	errs := make([]error, len(items))
+
+	groupCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	first := -1
+	for i, item := range items {
+		errorGroup.Go(func() error {
+			// items which have not started when the run fails fast are not run at all
+			if failFast && groupCtx.Err() != nil {
+				return nil
+			}
+			results[i], errs[i] = Limit(limiter, func() (R, error) {
+				result, err := fn(item, groupCtx)
+				// cancel while still holding the slot, so no waiting item starts after the failure
+				if err != nil && failFast {
+					once.Do(func() {
+						first = i
+						cancel()
+					})
+				}
+
+				return result, err
+			}, groupCtx)
+
+			return nil
+		})
+	}
+	_ = errorGroup.Wait()
+
+	// when failing fast only the first error is returned, the others are mostly caused by the cancellation
+	if first >= 0 {
+		for i := range errs {
+			if i != first {
+				errs[i] = nil
+			}
+		}
+	}
+
+	return results, errs
+}
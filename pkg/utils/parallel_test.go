@@ -0,0 +1,130 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMap(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		items          []int
+		concurrency    int
+		expectedValue  []int
+		expectedErrors []error
+	}{
+		{name: "results keep the order of items",
+			items:          []int{5, 4, 3, 2, 1},
+			concurrency:    5,
+			expectedValue:  []int{10, 8, 6, 4, 2},
+			expectedErrors: []error{nil, nil, nil, nil, nil},
+		},
+		{name: "errors are returned next to their items",
+			items:          []int{1, 0, 3},
+			concurrency:    2,
+			expectedValue:  []int{2, 0, 6},
+			expectedErrors: []error{nil, errors.New("zero is not allowed"), nil},
+		},
+		{name: "concurrency lower than one runs serially",
+			items:          []int{1, 2},
+			concurrency:    0,
+			expectedValue:  []int{2, 4},
+			expectedErrors: []error{nil, nil},
+		},
+	}
+
+	for _, test := range tests {
+		fmt.Printf("test: %s\n", test.name)
+
+		output, errs := Map(test.items, test.concurrency, func(i int) (int, error) {
+			time.Sleep(time.Duration(i) * time.Millisecond)
+			if i == 0 {
+				return 0, errors.New("zero is not allowed")
+			}
+
+			return i * 2, nil
+		})
+
+		assert.Equal(t, test.expectedValue, output)
+		assert.Equal(t, test.expectedErrors, errs)
+	}
+}
+
+func TestMapConcurrencyLimit(t *testing.T) {
+	t.Parallel()
+
+	var inFlight, maxInFlight atomic.Int32
+
+	_, _ = Map(make([]int, 20), 3, func(int) (int, error) {
+		current := inFlight.Add(1)
+		for {
+			seen := maxInFlight.Load()
+			if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		inFlight.Add(-1)
+
+		return 0, nil
+	})
+
+	assert.LessOrEqual(t, maxInFlight.Load(), int32(3))
+}
+
+func TestMapContextFailFast(t *testing.T) {
+	t.Parallel()
+
+	var calls atomic.Int32
+
+	_, errs := MapContext(make([]int, 20), NewLimiter(1), true, func(int, context.Context) (int, error) {
+		calls.Add(1)
+
+		return 0, errors.New("failed")
+	}, context.Background())
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, 1, failed)
+}
+
+func TestMapContextSharedLimiter(t *testing.T) {
+	t.Parallel()
+
+	var inFlight, maxInFlight atomic.Int32
+	limiter := NewLimiter(3)
+
+	_, _ = MapContext(make([]int, 5), nil, false, func(_ int, ctx context.Context) ([]int, error) {
+		results, _ := MapContext(make([]int, 5), limiter, false, func(_ int, _ context.Context) (int, error) {
+			current := inFlight.Add(1)
+			for {
+				seen := maxInFlight.Load()
+				if current <= seen || maxInFlight.CompareAndSwap(seen, current) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			inFlight.Add(-1)
+
+			return 0, nil
+		}, ctx)
+
+		return results, nil
+	}, context.Background())
+
+	assert.LessOrEqual(t, maxInFlight.Load(), int32(3))
+}